@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"pgboundary/internal/boundary"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage cached Boundary tokens",
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show cached Boundary tokens",
+	Args:  cobra.NoArgs,
+	RunE:  runAuthStatus,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout [host]",
+	Short: "Revoke and remove cached Boundary tokens",
+	Long: `Revoke and remove cached Boundary tokens.
+If a controller address is provided, only tokens for that controller are removed.
+Without arguments, all cached tokens are removed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuthLogout,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runAuthStatus(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	store := boundary.DefaultTokenStore()
+	tokens, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		fmt.Fprintln(out, "No cached tokens")
+		return nil
+	}
+
+	fmt.Fprintln(out, "Cached tokens:")
+	for _, token := range tokens {
+		status := fmt.Sprintf("expires in %s", time.Until(token.ExpirationTime).Round(time.Minute))
+		if token.Expired() {
+			status = "expired"
+		}
+
+		fmt.Fprintf(out, "  %s:\n", token.Controller)
+		fmt.Fprintf(out, "    Scope:       %s\n", token.ScopeId)
+		fmt.Fprintf(out, "    Auth Method: %s\n", token.AuthMethodId)
+		if token.LoginName != "" {
+			fmt.Fprintf(out, "    Login Name:  %s\n", token.LoginName)
+		}
+		fmt.Fprintf(out, "    Token:       %s\n", token.Id)
+		fmt.Fprintf(out, "    Expiration:  %s (%s)\n", token.ExpirationTime.Local().Format(time.RFC3339), status)
+		fmt.Fprintln(out)
+	}
+	if process.Verbose {
+		fmt.Fprintf(out, "Token cache: %s\n", store.Path)
+	}
+	return nil
+}
+
+func runAuthLogout(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	var host string
+	if len(args) == 1 {
+		host = args[0]
+	}
+
+	removed, err := boundary.DefaultTokenStore().Purge(host)
+	if err != nil {
+		return fmt.Errorf("failed to purge token cache: %w", err)
+	}
+
+	for _, token := range removed {
+		if !token.Expired() {
+			if err := boundary.RevokeToken(token); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		fmt.Fprintf(out, "Removed token %s for %s\n", token.Id, token.Controller)
+	}
+	if len(removed) == 0 {
+		fmt.Fprintln(out, "No cached tokens")
+	}
+	return nil
+}
+
+func init() {
+	authCmd.AddCommand(authStatusCmd, authLogoutCmd)
+}
@@ -1,69 +1,367 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 
+	"pgboundary/config"
 	"pgboundary/internal/boundary"
 	"pgboundary/internal/pgbouncer"
 	"pgboundary/internal/process"
+	"pgboundary/internal/state"
 
 	"github.com/spf13/cobra"
 )
 
 var connectCmd = &cobra.Command{
-	Use:   "connect [target]",
-	Short: "Connect to a target",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runConnect,
+	Use:   "connect [target...]",
+	Short: "Connect to one or more targets",
+	Long: `Connect to targets through boundary and expose them via pgbouncer.
+Several targets, all targets (--all) or the targets with a tag (--tag) are
+connected in parallel, authenticating once per controller, auth scope and
+auth method, and pgbouncer is reloaded once at the end.
+With --watch, pgboundary stays in the foreground and renews the session of
+a single target whenever the boundary process exits, until the target is
+shut down.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runConnect,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		process.Verbose, _ = cmd.Flags().GetBool("verbose")
 	},
 }
 
+var errConnectInterrupted = errors.New("connect interrupted")
+
+// connectResult is the outcome of connecting one target
+type connectResult struct {
+	target string
+	// auth has the login name resolved, so it is not asked for again on renewal
+	auth config.AuthConfig
+	conn *boundary.Connection
+	// undo ends the session and removes its database entry again
+	undo rollback
+	err  error
+	// alreadyConnected targets are skipped
+	alreadyConnected bool
+}
+
 func runConnect(cmd *cobra.Command, args []string) error {
-	target := args[0]
-	targetCfg, ok := Cfg.Targets[target]
-	if !ok {
-		return fmt.Errorf("target %q not found in configuration file", target)
+	out := cmd.OutOrStdout()
+
+	watch, _ := cmd.Flags().GetBool("watch")
+	maxRetries, _ := cmd.Flags().GetInt("max-retries")
+	all, _ := cmd.Flags().GetBool("all")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	if watch && maxRetries < 1 {
+		return fmt.Errorf("--max-retries must be at least 1 (got: %d)", maxRetries)
 	}
 
-	// Check if target is already connected
-	isConnected, err := pgbouncer.IsTargetConnected(Cfg, target)
+	targets, err := selectTargets(args, all, tags)
 	if err != nil {
-		return fmt.Errorf("failed to check target connection status: %w", err)
+		return err
+	}
+	if watch && len(targets) != 1 {
+		return fmt.Errorf("--watch supports a single target (got: %d)", len(targets))
 	}
-	if isConnected {
-		fmt.Printf("Warning: target %q is already connected\n", target)
+
+	if Cfg.PgBouncer.AuthFile != "" {
+		printAuthFileWarnings(Cfg.PgBouncer.AuthFile)
+	}
+
+	// Undo completed steps if a later one fails or the user interrupts
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := connectAndReload(ctx, targets)
+	stop()
+
+	if len(results) == 1 {
+		result := results[0]
+		switch {
+		case result.alreadyConnected:
+			fmt.Fprintf(out, "Warning: target %q is already connected\n", result.target)
+			return nil
+		case result.err != nil:
+			return result.err
+		}
+		if watch {
+			targetCfg := Cfg.Targets[result.target]
+			authScope, targetScope := targetScopes(targetCfg)
+			return watchConnection(cmd.Context(), out, result.target, targetCfg, result.auth, authScope, targetScope, result.conn, maxRetries)
+		}
 		return nil
 	}
 
-	// Get authentication scope
-	authScope := targetCfg.Auth
-	if authScope == "" {
-		authScope = Cfg.Scopes.Auth
+	return printConnectSummary(out, results)
+}
+
+// connectAndReload connects the targets and reloads pgbouncer once for all of
+// them. If the reload fails or ctx is canceled, all new targets are rolled back.
+func connectAndReload(ctx context.Context, targets []string) []*connectResult {
+	results := connectTargets(ctx, targets)
+
+	var connected []*connectResult
+	for _, result := range results {
+		if result.err == nil && result.conn != nil {
+			connected = append(connected, result)
+		}
+	}
+	if len(connected) == 0 {
+		return results
 	}
 
-	// Get target scope
-	targetScope := targetCfg.Scope
-	if targetScope == "" {
-		targetScope = Cfg.Scopes.Target
+	reloadErr := errConnectInterrupted
+	if ctx.Err() == nil {
+		reloadErr = pgbouncer.Reload(Cfg)
+	}
+	if reloadErr != nil {
+		for _, result := range connected {
+			err := reloadErr
+			if !errors.Is(err, errConnectInterrupted) {
+				err = fmt.Errorf("failed to reload pgbouncer after adding target %q: %w", result.target, reloadErr)
+			}
+			result.err = result.undo.run(err)
+			result.conn = nil
+		}
+	}
+	return results
+}
+
+// selectTargets returns the sorted names of the targets given as arguments,
+// all targets, or the targets with one of the tags
+func selectTargets(args []string, all bool, tags []string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, name := range args {
+		if _, ok := Cfg.Targets[name]; !ok {
+			return nil, fmt.Errorf("target %q not found in configuration file", name)
+		}
+		selected[name] = true
+	}
+
+	for _, tag := range tags {
+		found := false
+		for name, target := range Cfg.Targets {
+			if target.HasTag(tag) {
+				selected[name] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no targets tagged %q in configuration file", tag)
+		}
+	}
+
+	if all {
+		for name := range Cfg.Targets {
+			selected[name] = true
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, errors.New("no target given, pass target names, --all or --tag")
+	}
+
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// connectTargets connects the targets in parallel. Targets that fail are
+// rolled back; the others are registered but pgbouncer is not reloaded yet.
+func connectTargets(ctx context.Context, targets []string) []*connectResult {
+	results := make([]*connectResult, len(targets))
+
+	var pending []int
+	for i, target := range targets {
+		results[i] = &connectResult{target: target}
+		isConnected, err := pgbouncer.IsTargetConnected(Cfg, target)
+		switch {
+		case err != nil:
+			results[i].err = fmt.Errorf("failed to check target connection status: %w", err)
+		case isConnected:
+			results[i].alreadyConnected = true
+		default:
+			pending = append(pending, i)
+		}
+	}
+
+	pending = resolveLoginNames(ctx, results, pending)
+
+	// Authenticate up front, so parallel connects share the cached tokens
+	if len(pending) > 1 {
+		loginErrs := loginOnce(ctx, results, pending)
+		pending = slices.DeleteFunc(pending, func(i int) bool {
+			results[i].err = loginErrs[targets[i]]
+			return results[i].err != nil
+		})
+	}
+
+	var wg sync.WaitGroup
+	for _, i := range pending {
+		wg.Go(func() {
+			connectTarget(ctx, results[i])
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// resolveLoginNames sets the auth settings of the pending targets, reading or
+// prompting for each login name source once, and returns the targets left to
+// connect
+func resolveLoginNames(ctx context.Context, results []*connectResult, pending []int) []int {
+	type source struct {
+		method string
+		config.CredentialSource
+	}
+	type resolved struct {
+		auth config.AuthConfig
+		err  error
+	}
+	sources := make(map[source]resolved)
+
+	return slices.DeleteFunc(pending, func(i int) bool {
+		auth := Cfg.TargetAuth(Cfg.Targets[results[i].target])
+		key := source{auth.Method, auth.LoginName}
+		r, done := sources[key]
+		if !done {
+			r.auth, r.err = boundary.ResolveLoginName(ctx, auth)
+			sources[key] = r
+		}
+		if r.err != nil {
+			results[i].err = fmt.Errorf("failed to authenticate: %w", r.err)
+			return true
+		}
+		auth.LoginName = r.auth.LoginName
+		results[i].auth = auth
+		return false
+	})
+}
+
+// loginOnce authenticates once per controller, auth scope, auth method and
+// login name, so users see a single prompt or browser window for targets
+// sharing them. The tokens end up in the token cache, where connecting picks
+// them up.
+func loginOnce(ctx context.Context, results []*connectResult, pending []int) map[string]error {
+	logins := make(map[loginKey]error)
+	errs := make(map[string]error)
+
+	for _, i := range pending {
+		targetCfg := Cfg.Targets[results[i].target]
+		authScope, _ := targetScopes(targetCfg)
+		auth := results[i].auth
+		key := targetLoginKey(results[i])
+
+		err, done := logins[key]
+		switch {
+		case done:
+		case ctx.Err() != nil:
+			// Do not start further logins once interrupted
+			err = errConnectInterrupted
+		default:
+			_, err = boundary.Login(ctx, targetCfg.Host, authScope, auth)
+			logins[key] = err
+		}
+		if err != nil {
+			errs[results[i].target] = fmt.Errorf("failed to authenticate: %w", err)
+		}
 	}
+	return errs
+}
+
+// loginKey identifies the targets that can share a login
+type loginKey struct {
+	host, authScope, method, loginName string
+}
 
-	// Start boundary connection
-	boundaryConn, err := boundary.StartConnection(targetCfg, authScope, targetScope, Cfg.Auth.Method)
+func targetLoginKey(result *connectResult) loginKey {
+	targetCfg := Cfg.Targets[result.target]
+	authScope, _ := targetScopes(targetCfg)
+	return loginKey{strings.TrimRight(targetCfg.Host, "/"), authScope, result.auth.Method, result.auth.LoginName.Value}
+}
+
+// connectTarget starts the boundary session of a target and adds it to the
+// generated pgbouncer config, undoing both on failure or interrupt
+func connectTarget(ctx context.Context, result *connectResult) {
+	targetCfg := Cfg.Targets[result.target]
+	authScope, targetScope := targetScopes(targetCfg)
+
+	conn, err := boundary.StartConnection(ctx, targetCfg, authScope, targetScope, result.auth, Cfg.Boundary.ReadyTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to start boundary connection: %w", err)
+		result.err = fmt.Errorf("failed to start boundary connection: %w", err)
+		return
+	}
+	result.undo.add("end boundary session", func() error {
+		return boundary.Shutdown([]state.Session{conn.Session(result.target)})
+	})
+	if ctx.Err() != nil {
+		result.err = result.undo.run(errConnectInterrupted)
+		return
 	}
 
-	// Update pgbouncer configuration
-	if err := pgbouncer.UpdateConfig(Cfg, target, boundaryConn); err != nil {
-		return fmt.Errorf("failed to update pgbouncer configuration for target %q: %w", target, err)
+	if err := pgbouncer.UpdateConfig(Cfg, result.target, conn); err != nil {
+		result.err = result.undo.run(fmt.Errorf("failed to update pgbouncer configuration for target %q: %w", result.target, err))
+		return
+	}
+	result.undo.add("remove pgbouncer database entry", func() error {
+		return pgbouncer.RemoveConnection(Cfg, result.target)
+	})
+	if ctx.Err() != nil {
+		result.err = result.undo.run(errConnectInterrupted)
+		return
 	}
 
-	// Reload or start pgbouncer
-	if err := pgbouncer.Reload(Cfg); err != nil {
-		return fmt.Errorf("failed to reload pgbouncer after adding target %q: %w", target, err)
+	result.conn = conn
+}
+
+func printConnectSummary(out io.Writer, results []*connectResult) error {
+	failed := 0
+	fmt.Fprintln(out, "Connect summary:")
+	for _, result := range results {
+		switch {
+		case result.alreadyConnected:
+			fmt.Fprintf(out, "  %s: already connected\n", result.target)
+		case result.err != nil:
+			failed++
+			fmt.Fprintf(out, "  %s: failed: %v\n", result.target, result.err)
+		default:
+			fmt.Fprintf(out, "  %s: connected\n", result.target)
+		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed to connect", failed, len(results))
+	}
 	return nil
 }
+
+// targetScopes returns the auth and target scope of a target, falling back to the [scopes] section
+func targetScopes(target config.Target) (string, string) {
+	authScope := target.Auth
+	if authScope == "" {
+		authScope = Cfg.Scopes.Auth
+	}
+	targetScope := target.Scope
+	if targetScope == "" {
+		targetScope = Cfg.Scopes.Target
+	}
+	return authScope, targetScope
+}
+
+func init() {
+	connectCmd.Flags().Bool("watch", false, "stay in the foreground and renew the session when it ends")
+	connectCmd.Flags().Int("max-retries", 5, "reconnect attempts per renewal in --watch mode")
+	connectCmd.Flags().Bool("all", false, "connect all targets of the configuration file")
+	connectCmd.Flags().StringSlice("tag", nil, "connect the targets with this tag (repeatable)")
+}
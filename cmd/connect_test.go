@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"pgboundary/config"
+)
+
+func TestSelectTargets(t *testing.T) {
+	Cfg = &config.Config{Targets: map[string]config.Target{
+		"dev":       {Tags: []string{"dev"}},
+		"dev-2":     {Tags: []string{"dev", "reporting"}},
+		"stage":     {Tags: []string{"stage"}},
+		"reporting": {Tags: []string{"reporting"}},
+	}}
+
+	tests := []struct {
+		name    string
+		args    []string
+		all     bool
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "names", args: []string{"stage", "dev"}, want: []string{"dev", "stage"}},
+		{name: "tag", tags: []string{"reporting"}, want: []string{"dev-2", "reporting"}},
+		{name: "names and tags", args: []string{"stage"}, tags: []string{"dev"}, want: []string{"dev", "dev-2", "stage"}},
+		{name: "all", all: true, want: []string{"dev", "dev-2", "reporting", "stage"}},
+		{name: "unknown target", args: []string{"prod"}, wantErr: true},
+		{name: "unknown tag", tags: []string{"prod"}, wantErr: true},
+		{name: "nothing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectTargets(tt.args, tt.all, tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selectTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLoginNames(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	command := "echo >> " + calls + "; echo alice"
+	Cfg = &config.Config{
+		Auth:   config.AuthConfig{Method: "password", LoginName: config.CredentialSource{Command: command}},
+		Scopes: config.ScopesConfig{Auth: "global"},
+		Targets: map[string]config.Target{
+			"billing": {Host: "https://boundary.example.com/", Target: "db", Scope: "billing"},
+			"orders":  {Host: "https://boundary.example.com", Target: "db", Scope: "orders"},
+			"reports": {Host: "https://boundary.example.com", Target: "db", Scope: "reports", LoginName: config.CredentialSource{Value: "bob"}},
+		},
+	}
+
+	results := []*connectResult{{target: "billing"}, {target: "orders"}, {target: "reports"}}
+	pending := resolveLoginNames(context.Background(), results, []int{0, 1, 2})
+	if len(pending) != 3 {
+		t.Fatalf("pending = %v, errors: %v, %v, %v", pending, results[0].err, results[1].err, results[2].err)
+	}
+
+	// The login name command of the shared [auth] section runs once
+	if content, err := os.ReadFile(calls); err != nil || strings.Count(string(content), "\n") != 1 {
+		t.Errorf("login name command ran %d times, want once (err: %v)", strings.Count(string(content), "\n"), err)
+	}
+
+	billing, orders, reports := targetLoginKey(results[0]), targetLoginKey(results[1]), targetLoginKey(results[2])
+	if billing != orders || billing.loginName != "alice" {
+		t.Errorf("targets of alice do not share a login: %+v, %+v", billing, orders)
+	}
+	// Targets of different users must not share a login or its cached token
+	if reports == billing || reports.loginName != "bob" {
+		t.Errorf("target of bob shares the login of alice: %+v", reports)
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"pgboundary/config"
+	"pgboundary/internal/boundary"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discover Boundary targets you are authorized to connect to",
+	Long: `Discover Boundary targets you are authorized to connect to.
+Targets are listed recursively from the given scope. With --write, the
+discovered targets are added to the [targets] section of the config file.`,
+	Args: cobra.NoArgs,
+	RunE: runDiscover,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runDiscover(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	host, _ := cmd.Flags().GetString("host")
+	scope, _ := cmd.Flags().GetString("scope")
+	write, _ := cmd.Flags().GetBool("write")
+
+	if !strings.HasPrefix(host, "https://") {
+		return fmt.Errorf("host must start with https:// (got: %s)", host)
+	}
+
+	authScope, _ := cmd.Flags().GetString("auth-scope")
+	if authScope == "" {
+		authScope = Cfg.Scopes.Auth
+	}
+
+	client, err := boundary.Login(cmd.Context(), host, authScope, Cfg.Auth)
+	if err != nil {
+		return err
+	}
+
+	infos, err := boundary.ListTargets(client, scope)
+	if err != nil {
+		return err
+	}
+
+	var connectable []boundary.TargetInfo
+	for _, info := range infos {
+		if info.CanConnect() {
+			connectable = append(connectable, info)
+		}
+	}
+
+	if len(connectable) == 0 {
+		fmt.Fprintf(out, "No targets found on %s that you are authorized to connect to\n", host)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Targets on %s:\n", host)
+	for _, info := range connectable {
+		fmt.Fprintf(out, "  %s:\n", info.Name)
+		fmt.Fprintf(out, "    Id:          %s\n", info.Id)
+		fmt.Fprintf(out, "    Scope:       %s (%s)\n", info.ScopePath, info.ScopeId)
+		fmt.Fprintf(out, "    Type:        %s\n", info.Type)
+		fmt.Fprintf(out, "    Actions:     %s\n", strings.Join(info.AuthorizedActions, ", "))
+		fmt.Fprintln(out)
+	}
+
+	if !write {
+		return nil
+	}
+
+	names, targets, err := discoveredTargets(host, authScope, connectable)
+	if err != nil {
+		return err
+	}
+	added, err := config.AddTargets(Cfg.Path, names, targets)
+	if err != nil {
+		return fmt.Errorf("failed to write targets: %w", err)
+	}
+
+	for _, name := range added {
+		fmt.Fprintf(out, "Added target %s = %s\n", name, config.FormatTarget(targets[name]))
+	}
+	if skipped := len(names) - len(added); skipped > 0 {
+		fmt.Fprintf(out, "Skipped %d target(s) already present in the config file\n", skipped)
+	}
+	return nil
+}
+
+// discoveredTargets turns discovered Boundary targets into config entries, keyed
+// by target name, or by scope path and target name where names are ambiguous
+func discoveredTargets(host, authScope string, infos []boundary.TargetInfo) ([]string, map[string]config.Target, error) {
+	counts := make(map[string]int)
+	for _, info := range infos {
+		counts[configKey(info.Name)]++
+	}
+
+	var names []string
+	targets := make(map[string]config.Target)
+	sources := make(map[string]boundary.TargetInfo)
+	for _, info := range infos {
+		if strings.ContainsAny(info.Name+info.ScopePath, " \t") {
+			fmt.Fprintf(os.Stderr, "Warning: skipping target %q in scope %q, names with whitespace cannot be configured\n", info.Name, info.ScopePath)
+			continue
+		}
+
+		name := configKey(info.Name)
+		if counts[name] > 1 {
+			name = configKey(strings.ReplaceAll(info.ScopePath, "/", "-") + "-" + info.Name)
+		}
+		if other, ok := sources[name]; ok {
+			return nil, nil, fmt.Errorf("targets %q in scope %q and %q in scope %q both map to the config key %q",
+				other.Name, other.ScopePath, info.Name, info.ScopePath, name)
+		}
+		sources[name] = info
+
+		target := config.Target{
+			Host:   host,
+			Target: info.Name,
+			Scope:  info.ScopePath,
+		}
+		if authScope != Cfg.Scopes.Auth {
+			target.Auth = authScope
+		}
+
+		names = append(names, name)
+		targets[name] = target
+	}
+	return names, targets, nil
+}
+
+func configKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+func init() {
+	discoverCmd.Flags().String("host", "", "Boundary controller address (https://...)")
+	discoverCmd.Flags().String("scope", "global", "scope to list targets from, recursively")
+	discoverCmd.Flags().String("auth-scope", "", "authentication scope (default: auth scope from the config file)")
+	discoverCmd.Flags().Bool("write", false, "add the discovered targets to the config file")
+	_ = discoverCmd.MarkFlagRequired("host")
+}
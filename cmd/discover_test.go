@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"pgboundary/config"
+	"pgboundary/internal/boundary"
+)
+
+func TestDiscoveredTargets(t *testing.T) {
+	Cfg = &config.Config{Scopes: config.ScopesConfig{Auth: "global"}}
+	host := "https://boundary.example.com"
+
+	tests := []struct {
+		name    string
+		infos   []boundary.TargetInfo
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "unique names",
+			infos: []boundary.TargetInfo{
+				{Name: "Orders", ScopeName: "dev", ScopePath: "acme/dev"},
+				{Name: "billing", ScopeName: "dev", ScopePath: "acme/dev"},
+			},
+			want: []string{"orders", "billing"},
+		},
+		{
+			name: "same name in projects of the same name",
+			infos: []boundary.TargetInfo{
+				{Name: "db", ScopeName: "dev", ScopePath: "acme/dev"},
+				{Name: "db", ScopeName: "dev", ScopePath: "globex/dev"},
+			},
+			want: []string{"acme-dev-db", "globex-dev-db"},
+		},
+		{
+			name: "names differing in case",
+			infos: []boundary.TargetInfo{
+				{Name: "DB", ScopeName: "dev", ScopePath: "acme/dev"},
+				{Name: "db", ScopeName: "stage", ScopePath: "acme/stage"},
+			},
+			want: []string{"acme-dev-db", "acme-stage-db"},
+		},
+		{
+			name: "whitespace is skipped",
+			infos: []boundary.TargetInfo{
+				{Name: "db", ScopeName: "my project", ScopePath: "acme/my project"},
+				{Name: "db", ScopeName: "dev", ScopePath: "acme/dev"},
+			},
+			want: []string{"acme-dev-db"},
+		},
+		{
+			name: "keys still colliding",
+			infos: []boundary.TargetInfo{
+				{Name: "db", ScopeName: "b-dev", ScopePath: "a/b-dev"},
+				{Name: "db", ScopeName: "dev", ScopePath: "a-b/dev"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, targets, err := discoveredTargets(host, "global", tt.infos)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoveredTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("discoveredTargets() names = %v, want %v", names, tt.want)
+			}
+			if len(targets) != len(names) {
+				t.Errorf("discoveredTargets() returned %d targets for %d names", len(targets), len(names))
+			}
+		})
+	}
+}
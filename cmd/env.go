@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var envFormats = []string{"shell", "fish", "dotenv", "json", "url", "jdbc"}
+
+var envCmd = &cobra.Command{
+	Use:   "env [target]",
+	Short: "Print the connection settings of a target",
+	Long: `Print how to reach a target through pgbouncer, as environment variables
+(PGHOST, PGPORT, PGDATABASE, PGUSER, PGPASSWORD and DATABASE_URL) or as a
+libpq or JDBC URL. The target does not need to be connected.
+
+  eval "$(pgboundary env demo-dev)"
+  pgboundary env demo-dev --format fish | source`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnv,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	format, _ := cmd.Flags().GetString("format")
+	user, _ := cmd.Flags().GetString("user")
+	if !slices.Contains(envFormats, format) {
+		return fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(envFormats, ", "))
+	}
+
+	endpoint, err := pgbouncer.ClientEndpoint(Cfg, args[0], user)
+	if err != nil {
+		return err
+	}
+
+	output, err := formatEnv(endpoint, format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, output)
+	return nil
+}
+
+func formatEnv(endpoint *pgbouncer.Endpoint, format string) (string, error) {
+	var b strings.Builder
+	switch format {
+	case "shell":
+		for _, v := range endpoint.Variables() {
+			fmt.Fprintf(&b, "export %s=%s\n", v[0], shellQuote(v[1]))
+		}
+	case "fish":
+		for _, v := range endpoint.Variables() {
+			fmt.Fprintf(&b, "set -gx %s %s;\n", v[0], fishQuote(v[1]))
+		}
+	case "dotenv":
+		for _, v := range endpoint.Variables() {
+			fmt.Fprintf(&b, "%s=%s\n", v[0], dotenvQuote(v[1]))
+		}
+	case "json":
+		vars := make(map[string]string)
+		for _, v := range endpoint.Variables() {
+			vars[v[0]] = v[1]
+		}
+		content, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode variables: %w", err)
+		}
+		b.Write(content)
+		b.WriteByte('\n')
+	case "url":
+		b.WriteString(endpoint.URL() + "\n")
+	case "jdbc":
+		url, err := endpoint.JDBCURL()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(url + "\n")
+	default:
+		return "", fmt.Errorf("invalid format %q", format)
+	}
+	return b.String(), nil
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes a value for fish, where backslashes escape inside single quotes
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// dotenvQuote quotes a value for .env files if it contains special characters
+func dotenvQuote(value string) string {
+	if !strings.ContainsAny(value, " \t#'\"\\$=") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`).Replace(value) + `"`
+}
+
+func init() {
+	envCmd.Flags().String("format", "shell", "output format: "+strings.Join(envFormats, ", "))
+	envCmd.Flags().String("user", "", "pgbouncer user (default: first non-admin user of the auth_file)")
+}
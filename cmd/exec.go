@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+// ExitError makes pgboundary exit with the exit code of a command it ran
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [target] -- [command...]",
+	Short: "Run a command with the connection settings of a target",
+	Long: `Run a command against a target through pgbouncer, e.g. psql or a migration.
+The target is connected first unless it already is, and disconnected again
+afterwards if exec connected it. The command gets PGHOST, PGPORT, PGDATABASE,
+PGUSER, PGPASSWORD and DATABASE_URL pointing at pgbouncer, receives SIGTERM
+and SIGHUP sent to pgboundary (Ctrl-C reaches it from the terminal), and its
+exit code is passed on.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return errors.New("usage: pgboundary exec [target] -- [command...]")
+		}
+		return nil
+	},
+	RunE:          runExec,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	target, command := args[0], args[1:]
+	user, _ := cmd.Flags().GetString("user")
+
+	endpoint, err := pgbouncer.ClientEndpoint(Cfg, target, user)
+	if err != nil {
+		return err
+	}
+
+	connected, err := pgbouncer.IsTargetConnected(Cfg, target)
+	if err != nil {
+		return fmt.Errorf("failed to check target connection status: %w", err)
+	}
+	if !connected {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		result := connectAndReload(ctx, []string{target})[0]
+		stop()
+		if result.err != nil {
+			return result.err
+		}
+		defer func() {
+			if err := pgbouncer.ShutdownConnection(Cfg, target); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to disconnect target %q: %v\n", target, err)
+			}
+		}()
+	}
+
+	return runWithEnvironment(command, endpoint.Environment())
+}
+
+// runWithEnvironment runs a command with additional environment variables,
+// forwarding SIGTERM and SIGHUP to it, and returns an ExitError if it does not succeed
+func runWithEnvironment(command []string, env []string) error {
+	child := exec.Command(command[0], command[1:]...)
+	child.Env = append(os.Environ(), env...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// Catch signals before starting, so none terminates pgboundary before cleanup.
+	// The terminal delivers SIGINT and SIGQUIT to the child itself, as it runs in
+	// our foreground process group, so only the others are forwarded. signal.Ignore
+	// is not used, since ignored signals would be inherited by the child.
+	terminalSignals := make(chan os.Signal, 1)
+	signal.Notify(terminalSignals, os.Interrupt, syscall.SIGQUIT)
+	defer signal.Stop(terminalSignals)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", command[0], err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = child.Process.Signal(sig)
+			case <-terminalSignals:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+		return &ExitError{Code: code}
+	}
+	return err
+}
+
+func init() {
+	execCmd.Flags().String("user", "", "pgbouncer user (default: first non-admin user of the auth_file)")
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunWithEnvironmentSignals(t *testing.T) {
+	// SIGINT comes from the terminal, so only SIGTERM may reach the command
+	time.AfterFunc(300*time.Millisecond, func() {
+		_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		time.Sleep(100 * time.Millisecond)
+		_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	})
+
+	err := runWithEnvironment([]string{"sh", "-c", `trap "exit 3" INT; trap "exit 7" TERM; sleep 5 >/dev/null 2>&1 & wait`}, nil)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 7 {
+		t.Errorf("runWithEnvironment() = %v, want exit code 7", err)
+	}
+}
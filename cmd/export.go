@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+// Marker comments around the entries managed by pgboundary in other tools' files
+const (
+	blockBeginPrefix = "# BEGIN pgboundary"
+	blockBegin       = blockBeginPrefix + ", managed by `pgboundary export`, do not edit"
+	blockEnd         = "# END pgboundary"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the targets for other tools",
+	Long: `Export the configured targets as connection settings for other tools. Every
+target points at the local pgbouncer listener with the local auth_file user.`,
+}
+
+var exportPgServiceCmd = &cobra.Command{
+	Use:   "pgservice",
+	Short: "Export the targets as libpq service file sections",
+	Long: `Print a pg_service.conf section per target, so libpq tools connect with
+e.g. psql service=demo-dev. With --write, the sections are merged into the
+service file ($PGSERVICEFILE or ~/.pg_service.conf) between marker comments,
+leaving the rest of the file untouched.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(cmd, renderPgService, os.Getenv("PGSERVICEFILE"), ".pg_service.conf", 0644)
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+var exportPgPassCmd = &cobra.Command{
+	Use:   "pgpass",
+	Short: "Export the targets as password file entries",
+	Long: `Print a .pgpass line per target whose password is known, i.e. stored in plain
+text in the auth_file or set in PGBOUNDARY_PASSWORD. With --write, the lines
+are merged into the password file ($PGPASSFILE or ~/.pgpass) between marker
+comments, leaving the rest of the file untouched.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(cmd, renderPgPass, os.Getenv("PGPASSFILE"), ".pgpass", 0600)
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+// runExport renders the endpoints of all targets and prints them or, with
+// --write, merges them into path (default: defaultName in the home directory)
+func runExport(cmd *cobra.Command, render func([]*pgbouncer.Endpoint) []string, path, defaultName string, perm os.FileMode) error {
+	out := cmd.OutOrStdout()
+
+	write, _ := cmd.Flags().GetBool("write")
+	file, _ := cmd.Flags().GetString("file")
+	user, _ := cmd.Flags().GetString("user")
+
+	endpoints, err := targetEndpoints(user)
+	if err != nil {
+		return err
+	}
+	block := append(append([]string{blockBegin}, render(endpoints)...), blockEnd)
+
+	if !write {
+		fmt.Fprintln(out, strings.Join(block, "\n"))
+		return nil
+	}
+
+	if file != "" {
+		path = file
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, defaultName)
+	}
+
+	var existing string
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		existing = string(content)
+		// libpq ignores password files other users can read, other files keep their mode
+		if info, err := os.Stat(path); err == nil && perm != 0600 {
+			perm = info.Mode().Perm()
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	merged, err := mergeBlock(existing, block)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	if err := fileutil.WriteFileAtomic(path, []byte(merged), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "Exported %d target(s) to %s\n", len(endpoints), path)
+	return nil
+}
+
+// targetEndpoints resolves the pgbouncer endpoints of all targets, sorted by name
+func targetEndpoints(user string) ([]*pgbouncer.Endpoint, error) {
+	names := make([]string, 0, len(Cfg.Targets))
+	for name := range Cfg.Targets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	endpoints := make([]*pgbouncer.Endpoint, 0, len(names))
+	for _, name := range names {
+		endpoint, err := pgbouncer.ClientEndpoint(Cfg, name, user)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// renderPgService returns a service file section per endpoint
+func renderPgService(endpoints []*pgbouncer.Endpoint) []string {
+	var lines []string
+	for i, e := range endpoints {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines,
+			"["+e.Database+"]",
+			"host="+e.Host,
+			"port="+strconv.Itoa(e.Port),
+			"dbname="+e.Database,
+			"user="+e.User,
+		)
+	}
+	return lines
+}
+
+// renderPgPass returns a password file line per endpoint with a known password
+func renderPgPass(endpoints []*pgbouncer.Endpoint) []string {
+	escape := strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace
+
+	var lines []string
+	for _, e := range endpoints {
+		if e.Password == "" {
+			fmt.Fprintf(os.Stderr, "Warning: skipping target %q, the password of %q is not stored in plain text, set %s\n", e.Database, e.User, pgbouncer.ClientPasswordEnv)
+			continue
+		}
+		// libpq matches unix socket connections against localhost
+		host := e.Host
+		if e.IsUnixSocket() {
+			host = "localhost"
+		}
+		lines = append(lines, strings.Join([]string{
+			escape(host), strconv.Itoa(e.Port), escape(e.Database), escape(e.User), escape(e.Password),
+		}, ":"))
+	}
+	return lines
+}
+
+// mergeBlock replaces the marked block in content, or appends it if there is none
+func mergeBlock(content string, block []string) (string, error) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	begin := slices.IndexFunc(lines, func(line string) bool {
+		return strings.HasPrefix(strings.TrimSpace(line), blockBeginPrefix)
+	})
+	if begin < 0 {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+		return strings.Join(lines, "\n") + "\n", nil
+	}
+
+	end := slices.IndexFunc(lines[begin:], func(line string) bool {
+		return strings.TrimSpace(line) == blockEnd
+	})
+	if end < 0 {
+		return "", errors.New("found the pgboundary begin marker but no end marker")
+	}
+	lines = slices.Replace(lines, begin, begin+end+1, block...)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{exportPgServiceCmd, exportPgPassCmd} {
+		cmd.Flags().Bool("write", false, "merge the entries into the file instead of printing them")
+		cmd.Flags().String("file", "", "file to write to with --write")
+		cmd.Flags().String("user", "", "pgbouncer user (default: first non-admin user of the auth_file)")
+		exportCmd.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var ideFormats = []string{"datagrip", "dbeaver", "pgadmin"}
+
+var exportIDECmd = &cobra.Command{
+	Use:   "ide",
+	Short: "Export the targets as IDE data sources",
+	Long: `Print data source definitions for all targets, to import into an IDE instead
+of setting up every connection by hand. The database is the target name and
+each data source only shows that database, which pgbouncer requires.
+
+  datagrip  .idea/dataSources.xml of a JetBrains project
+  dbeaver   .dbeaver/data-sources.json of a DBeaver project
+  pgadmin   servers.json for File > Import/Export Servers
+
+Passwords are not exported, the IDE asks for them on the first connect.`,
+	Args: cobra.NoArgs,
+	RunE: runExportIDE,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runExportIDE(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	format, _ := cmd.Flags().GetString("format")
+	file, _ := cmd.Flags().GetString("file")
+	user, _ := cmd.Flags().GetString("user")
+	if !slices.Contains(ideFormats, format) {
+		return fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(ideFormats, ", "))
+	}
+
+	endpoints, err := targetEndpoints(user)
+	if err != nil {
+		return err
+	}
+
+	content, err := renderIDE(endpoints, format)
+	if err != nil {
+		return err
+	}
+
+	if file == "" {
+		fmt.Fprint(out, string(content))
+		return nil
+	}
+	if err := fileutil.WriteFileAtomic(file, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	fmt.Fprintf(out, "Exported %d target(s) to %s\n", len(endpoints), file)
+	return nil
+}
+
+func renderIDE(endpoints []*pgbouncer.Endpoint, format string) ([]byte, error) {
+	switch format {
+	case "datagrip":
+		return renderDataGrip(endpoints)
+	case "dbeaver":
+		return renderDBeaver(endpoints)
+	case "pgadmin":
+		return renderPgAdmin(endpoints)
+	}
+	return nil, fmt.Errorf("invalid format %q", format)
+}
+
+type dataGripProject struct {
+	XMLName   xml.Name          `xml:"project"`
+	Version   string            `xml:"version,attr"`
+	Component dataGripComponent `xml:"component"`
+}
+
+type dataGripComponent struct {
+	Name        string               `xml:"name,attr"`
+	Format      string               `xml:"format,attr"`
+	DataSources []dataGripDataSource `xml:"data-source"`
+}
+
+type dataGripDataSource struct {
+	Source      string `xml:"source,attr"`
+	Name        string `xml:"name,attr"`
+	UUID        string `xml:"uuid,attr"`
+	DriverRef   string `xml:"driver-ref"`
+	Synchronize bool   `xml:"synchronize"`
+	JDBCDriver  string `xml:"jdbc-driver"`
+	JDBCURL     string `xml:"jdbc-url"`
+	WorkingDir  string `xml:"working-dir"`
+	// Introspecting only the target's database is what Single Database Mode does
+	Scope []dataGripNode `xml:"schema-mapping>introspection-scope>node"`
+}
+
+type dataGripNode struct {
+	Kind  string         `xml:"kind,attr"`
+	QName string         `xml:"qname,attr"`
+	Nodes []dataGripNode `xml:"node"`
+}
+
+func renderDataGrip(endpoints []*pgbouncer.Endpoint) ([]byte, error) {
+	project := dataGripProject{
+		Version:   "4",
+		Component: dataGripComponent{Name: "DataSourceManagerImpl", Format: "xml"},
+	}
+	for _, e := range endpoints {
+		// The password is entered in the IDE, only the user goes into the URL
+		withoutPassword := *e
+		withoutPassword.Password = ""
+		url, err := withoutPassword.JDBCURL()
+		if err != nil {
+			return nil, err
+		}
+		project.Component.DataSources = append(project.Component.DataSources, dataGripDataSource{
+			Source:      "LOCAL",
+			Name:        e.Database,
+			UUID:        stableUUID(e.Database),
+			DriverRef:   "postgresql",
+			Synchronize: true,
+			JDBCDriver:  "org.postgresql.Driver",
+			JDBCURL:     url,
+			WorkingDir:  "$ProjectFileDir$",
+			Scope: []dataGripNode{{
+				Kind:  "database",
+				QName: e.Database,
+				Nodes: []dataGripNode{{Kind: "schema", QName: "@"}},
+			}},
+		})
+	}
+
+	content, err := xml.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data sources: %w", err)
+	}
+	return append([]byte(xml.Header), append(content, '\n')...), nil
+}
+
+func renderDBeaver(endpoints []*pgbouncer.Endpoint) ([]byte, error) {
+	connections := make(map[string]any)
+	for _, e := range endpoints {
+		url, err := e.JDBCURL()
+		if err != nil {
+			return nil, err
+		}
+		url, _, _ = strings.Cut(url, "?")
+		connections["postgres-jdbc-pgboundary-"+e.Database] = map[string]any{
+			"provider":      "postgresql",
+			"driver":        "postgres-jdbc",
+			"name":          e.Database,
+			"save-password": false,
+			"folder":        "pgboundary",
+			"configuration": map[string]any{
+				"host":              e.Host,
+				"port":              fmt.Sprint(e.Port),
+				"database":          e.Database,
+				"url":               url,
+				"user":              e.User,
+				"configurationType": "MANUAL",
+				"type":              "dev",
+				"auth-model":        "native",
+				// Only show the target's database
+				"provider-properties": map[string]string{
+					"@dbeaver-show-non-default-db@": "false",
+					"@dbeaver-show-template-db@":    "false",
+					"@dbeaver-show-unavailable-db@": "false",
+				},
+			},
+		}
+	}
+
+	return marshalJSON(map[string]any{
+		"folders":     map[string]any{"pgboundary": map[string]any{}},
+		"connections": connections,
+	})
+}
+
+func renderPgAdmin(endpoints []*pgbouncer.Endpoint) ([]byte, error) {
+	servers := make(map[string]any)
+	for i, e := range endpoints {
+		servers[fmt.Sprint(i+1)] = map[string]any{
+			"Name":          e.Database,
+			"Group":         "pgboundary",
+			"Host":          e.Host,
+			"Port":          e.Port,
+			"MaintenanceDB": e.Database,
+			"Username":      e.User,
+			"SSLMode":       "prefer",
+			// Only show the target's database
+			"DBRestriction": e.Database,
+		}
+	}
+	return marshalJSON(map[string]any{"Servers": servers})
+}
+
+func marshalJSON(v any) ([]byte, error) {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data sources: %w", err)
+	}
+	return append(content, '\n'), nil
+}
+
+// stableUUID derives a UUID from name, so exporting again updates the data
+// sources instead of adding new ones
+func stableUUID(name string) string {
+	sum := sha1.Sum([]byte("pgboundary:" + name))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func init() {
+	exportIDECmd.Flags().String("format", "", "IDE: "+strings.Join(ideFormats, ", "))
+	exportIDECmd.Flags().String("file", "", "write to this file instead of printing")
+	exportIDECmd.Flags().String("user", "", "pgbouncer user (default: first non-admin user of the auth_file)")
+	_ = exportIDECmd.MarkFlagRequired("format")
+	exportCmd.AddCommand(exportIDECmd)
+}
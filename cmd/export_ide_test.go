@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"pgboundary/internal/pgbouncer"
+)
+
+func TestRenderIDE(t *testing.T) {
+	endpoints := []*pgbouncer.Endpoint{
+		{Host: "127.0.0.1", Port: 6432, Database: "demo-dev", User: "app", Password: "secret"},
+		{Host: "127.0.0.1", Port: 6432, Database: "demo-stage", User: "app"},
+	}
+
+	content, err := renderIDE(endpoints, "datagrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var project dataGripProject
+	if err := xml.Unmarshal(content, &project); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, content)
+	}
+	if got := len(project.Component.DataSources); got != 2 {
+		t.Fatalf("got %d data sources, want 2", got)
+	}
+	source := project.Component.DataSources[0]
+	if source.JDBCURL != "jdbc:postgresql://127.0.0.1:6432/demo-dev?user=app" {
+		t.Errorf("jdbc-url = %q", source.JDBCURL)
+	}
+	if len(source.Scope) != 1 || source.Scope[0].QName != "demo-dev" {
+		t.Errorf("introspection scope = %+v, want database demo-dev", source.Scope)
+	}
+	if source.UUID != stableUUID("demo-dev") || source.UUID == project.Component.DataSources[1].UUID {
+		t.Errorf("uuid = %q, want stable and unique", source.UUID)
+	}
+
+	for _, format := range []string{"dbeaver", "pgadmin"} {
+		content, err := renderIDE(endpoints, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(content) {
+			t.Errorf("%s: invalid JSON:\n%s", format, content)
+		}
+		if !strings.Contains(string(content), `"demo-stage"`) {
+			t.Errorf("%s: missing target demo-stage:\n%s", format, content)
+		}
+	}
+
+	for _, format := range []string{"datagrip", "dbeaver", "pgadmin"} {
+		content, _ := renderIDE(endpoints, format)
+		if strings.Contains(string(content), "secret") {
+			t.Errorf("%s: export contains the password", format)
+		}
+	}
+
+	// JDBC cannot connect to unix sockets
+	socket := []*pgbouncer.Endpoint{{Host: "/tmp", Port: 6432, Database: "demo-dev", User: "app"}}
+	if _, err := renderIDE(socket, "datagrip"); err == nil {
+		t.Error("renderIDE() with unix socket succeeded")
+	}
+}
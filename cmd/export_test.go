@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"pgboundary/internal/pgbouncer"
+)
+
+func TestMergeBlock(t *testing.T) {
+	block := []string{blockBegin, "new", blockEnd}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty file", "", blockBegin + "\nnew\n" + blockEnd + "\n"},
+		{"append", "mine\n", "mine\n\n" + blockBegin + "\nnew\n" + blockEnd + "\n"},
+		{"replace", "mine\n" + blockBegin + "\nold\nolder\n" + blockEnd + "\nafter\n", "mine\n" + blockBegin + "\nnew\n" + blockEnd + "\nafter\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeBlock(tt.content, block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("mergeBlock() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// Merging again changes nothing
+			if again, _ := mergeBlock(got, block); again != got {
+				t.Errorf("mergeBlock() is not idempotent:\n%s", again)
+			}
+		})
+	}
+
+	if _, err := mergeBlock(blockBegin+"\nold\n", block); err == nil {
+		t.Error("mergeBlock() without end marker succeeded")
+	}
+}
+
+func TestRenderPgPass(t *testing.T) {
+	endpoints := []*pgbouncer.Endpoint{
+		{Host: "127.0.0.1", Port: 6432, Database: "demo-dev", User: "app", Password: `a:b\c`},
+		{Host: "/tmp", Port: 6432, Database: "demo-stage", User: "app", Password: "pw"},
+		{Host: "127.0.0.1", Port: 6432, Database: "hashed", User: "ci"},
+	}
+	want := []string{`127.0.0.1:6432:demo-dev:app:a\:b\\c`, "localhost:6432:demo-stage:app:pw"}
+	if got := renderPgPass(endpoints); !reflect.DeepEqual(got, want) {
+		t.Errorf("renderPgPass() = %q, want %q", got, want)
+	}
+}
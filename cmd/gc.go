@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove leftover session files",
+	Long: `Remove pgbouncer config fragments holding session credentials that are no
+longer used, e.g. after a crash. Sessions whose boundary process is gone are
+unregistered first. Credential files readable by other users are reported.`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	result, err := pgbouncer.GarbageCollect(Cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range result.Unregistered {
+		fmt.Fprintf(out, "Removed connection %q, its boundary process is gone\n", target)
+	}
+	if len(result.Unregistered) > 0 {
+		if running, _, err := pgbouncer.CheckStatus(Cfg.PgBouncer.PidFile); err == nil && running {
+			if err := pgbouncer.Reload(Cfg); err != nil {
+				return fmt.Errorf("failed to reload pgbouncer: %w", err)
+			}
+		}
+	}
+
+	for _, path := range result.Removed {
+		if process.Verbose {
+			fmt.Fprintf(out, "removed %s\n", path)
+		}
+	}
+	fmt.Fprintf(out, "Removed %d leftover session file(s)\n", len(result.Removed))
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return nil
+}
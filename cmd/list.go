@@ -2,9 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"pgboundary/internal/pgbouncer"
 	"pgboundary/internal/process"
+	"pgboundary/internal/state"
 
 	"github.com/spf13/cobra"
 )
@@ -18,55 +25,208 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// listOutput is the schema of `list --output json|yaml`
+type listOutput struct {
+	statusOutput `yaml:",inline"`
+	Targets      []targetOutput `json:"targets" yaml:"targets"`
+}
+
+// statusOutput is the schema of `status --output json|yaml`
+type statusOutput struct {
+	PgBouncer   pgBouncerOutput    `json:"pgbouncer" yaml:"pgbouncer"`
+	Connections []connectionOutput `json:"connections" yaml:"connections"`
+}
+
+type pgBouncerOutput struct {
+	Running    bool   `json:"running" yaml:"running"`
+	Pid        int    `json:"pid,omitempty" yaml:"pid,omitempty"`
+	ListenAddr string `json:"listen_addr,omitempty" yaml:"listen_addr,omitempty"`
+	ListenPort int    `json:"listen_port" yaml:"listen_port"`
+	// StatsError is set if the admin console could not be queried
+	StatsError string `json:"stats_error,omitempty" yaml:"stats_error,omitempty"`
+}
+
+type connectionOutput struct {
+	Name        string       `json:"name" yaml:"name"`
+	BoundaryPid int          `json:"boundary_pid" yaml:"boundary_pid"`
+	Port        int          `json:"port" yaml:"port"`
+	ConnectedAt time.Time    `json:"connected_at" yaml:"connected_at"`
+	ExpiresAt   time.Time    `json:"expires_at" yaml:"expires_at"`
+	Stats       *statsOutput `json:"stats,omitempty" yaml:"stats,omitempty"`
+}
+
+type statsOutput struct {
+	ClientsActive  int      `json:"clients_active" yaml:"clients_active"`
+	ClientsWaiting int      `json:"clients_waiting" yaml:"clients_waiting"`
+	ServersActive  int      `json:"servers_active" yaml:"servers_active"`
+	ServersIdle    int      `json:"servers_idle" yaml:"servers_idle"`
+	TotalQueries   int64    `json:"total_queries" yaml:"total_queries"`
+	AvgQueryTimeUs int64    `json:"avg_query_time_us" yaml:"avg_query_time_us"`
+	Applications   []string `json:"applications,omitempty" yaml:"applications,omitempty"`
+}
+
+type targetOutput struct {
+	Name        string   `json:"name" yaml:"name"`
+	Host        string   `json:"host" yaml:"host"`
+	Target      string   `json:"target" yaml:"target"`
+	AuthScope   string   `json:"auth_scope" yaml:"auth_scope"`
+	TargetScope string   `json:"target_scope" yaml:"target_scope"`
+	Database    string   `json:"database,omitempty" yaml:"database,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
 func runList(cmd *cobra.Command, args []string) error {
-	// Check PgBouncer status
-	if running, pid, err := pgbouncer.CheckStatus(Cfg.PgBouncer.PidFile); err == nil && running {
-		if process.Verbose {
-			fmt.Printf("PgBouncer is running (pid: %d)\n", pid)
+	status, err := collectStatus()
+	if err != nil {
+		return err
+	}
+	output := listOutput{statusOutput: *status, Targets: collectTargets()}
+
+	return writeOutput(cmd, output, func(w io.Writer) {
+		if output.PgBouncer.Running {
+			if process.Verbose {
+				fmt.Fprintf(w, "PgBouncer is running (pid: %d)\n", output.PgBouncer.Pid)
+			}
+			fmt.Fprintln(w, "Active PgBouncer connections:")
+			printConnections(w, &output.statusOutput)
+			fmt.Fprintln(w)
+		} else if process.Verbose {
+			fmt.Fprintf(w, "PgBouncer is not running\n\n")
 		}
-		// Get PgBouncer connections
-		connections, err := pgbouncer.GetConnectionDetails(Cfg.PgBouncer.ConfFile)
-		if err != nil {
-			fmt.Printf("Error getting connections: %v\n", err)
-		} else {
-			fmt.Println("Active PgBouncer connections:")
-			for _, conn := range connections {
-				if process.Verbose && conn.BoundaryPid > 0 {
-					fmt.Printf("  %s (boundary pid: %d)\n", conn.Name, conn.BoundaryPid)
-				} else {
-					fmt.Printf("  %s\n", conn.Name)
-				}
+
+		fmt.Fprintln(w, "Available boundary targets:")
+		for _, target := range output.Targets {
+			fmt.Fprintf(w, "  %s:\n", target.Name)
+			fmt.Fprintf(w, "    Host:        %s\n", target.Host)
+			fmt.Fprintf(w, "    Target:      %s\n", target.Target)
+			fmt.Fprintf(w, "    Auth Scope:  %s\n", target.AuthScope)
+			fmt.Fprintf(w, "    Target Scope:%s\n", target.TargetScope)
+			if target.Database != "" {
+				fmt.Fprintf(w, "    Database:    %s\n", target.Database)
 			}
-			fmt.Println()
+			fmt.Fprintln(w)
 		}
-	} else if process.Verbose {
-		fmt.Printf("PgBouncer is not running\n\n")
+	})
+}
+
+// collectStatus returns whether pgbouncer is running and, if it is, the active
+// connections with their live statistics
+func collectStatus() (*statusOutput, error) {
+	status := &statusOutput{
+		PgBouncer: pgBouncerOutput{
+			ListenAddr: Cfg.PgBouncer.ListenAddr,
+			ListenPort: Cfg.PgBouncer.ListenPort,
+		},
+		Connections: []connectionOutput{},
 	}
 
-	// List boundary targets
-	fmt.Println("Available boundary targets:")
-	for name, target := range Cfg.Targets {
-		// Get auth scope, fallback to global config
-		authScope := target.Auth
-		if authScope == "" {
-			authScope = Cfg.Scopes.Auth
+	running, pid, err := pgbouncer.CheckStatus(Cfg.PgBouncer.PidFile)
+	if err != nil || !running {
+		return status, nil
+	}
+	status.PgBouncer.Running = true
+	status.PgBouncer.Pid = pid
+
+	sessions, err := state.Default().List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connections: %w", err)
+	}
+
+	var stats map[string]*pgbouncer.DatabaseStats
+	if len(sessions) > 0 {
+		if stats, err = databaseStats(); err != nil {
+			status.PgBouncer.StatsError = err.Error()
 		}
+	}
 
-		// Get target scope, fallback to global config
-		targetScope := target.Scope
-		if targetScope == "" {
-			targetScope = Cfg.Scopes.Target
+	for _, session := range sessions {
+		port, _ := strconv.Atoi(session.ProxyPort)
+		conn := connectionOutput{
+			Name:        session.Target,
+			BoundaryPid: session.BoundaryPid,
+			Port:        port,
+			ConnectedAt: session.ConnectedAt,
+			ExpiresAt:   session.Expiration,
+		}
+		if s, ok := stats[session.Target]; ok {
+			conn.Stats = &statsOutput{
+				ClientsActive:  s.ClientsActive,
+				ClientsWaiting: s.ClientsWaiting,
+				ServersActive:  s.ServersActive,
+				ServersIdle:    s.ServersIdle,
+				TotalQueries:   s.TotalQueries,
+				AvgQueryTimeUs: s.AvgQueryTime.Microseconds(),
+				Applications:   s.Applications,
+			}
 		}
+		status.Connections = append(status.Connections, conn)
+	}
+	return status, nil
+}
 
-		fmt.Printf("  %s:\n", name)
-		fmt.Printf("    Host:        %s\n", target.Host)
-		fmt.Printf("    Target:      %s\n", target.Target)
-		fmt.Printf("    Auth Scope:  %s\n", authScope)
-		fmt.Printf("    Target Scope:%s\n", targetScope)
-		if target.Database != "" {
-			fmt.Printf("    Database:    %s\n", target.Database)
+// collectTargets returns the configured targets sorted by name
+func collectTargets() []targetOutput {
+	targets := make([]targetOutput, 0, len(Cfg.Targets))
+	for name, target := range Cfg.Targets {
+		authScope, targetScope := targetScopes(target)
+		targets = append(targets, targetOutput{
+			Name:        name,
+			Host:        target.Host,
+			Target:      target.Target,
+			AuthScope:   authScope,
+			TargetScope: targetScope,
+			Database:    target.Database,
+			Tags:        target.Tags,
+		})
+	}
+	slices.SortFunc(targets, func(a, b targetOutput) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return targets
+}
+
+// databaseStats fetches live statistics from the pgbouncer admin console
+func databaseStats() (map[string]*pgbouncer.DatabaseStats, error) {
+	console, err := pgbouncer.ConnectAdmin(Cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := console.Close(); err != nil && process.Verbose {
+			fmt.Fprintf(os.Stderr, "failed to close admin console connection: %v\n", err)
+		}
+	}()
+
+	return console.DatabaseStats()
+}
+
+// printConnections prints the active connections. Statistics are informational
+// only, so failures to get them are shown but do not fail the command.
+func printConnections(w io.Writer, status *statusOutput) {
+	if status.PgBouncer.StatsError != "" {
+		fmt.Fprintf(w, "  (statistics unavailable: %s)\n", status.PgBouncer.StatsError)
+	}
+	for _, conn := range status.Connections {
+		if process.Verbose && conn.BoundaryPid > 0 {
+			fmt.Fprintf(w, "  %s (boundary pid: %d, port: %d, expires: %s)\n", conn.Name, conn.BoundaryPid,
+				conn.Port, conn.ExpiresAt.Local().Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "  %s (expires: %s)\n", conn.Name, conn.ExpiresAt.Local().Format(time.RFC3339))
+		}
+		if conn.Stats != nil {
+			printDatabaseStats(w, conn.Stats)
 		}
-		fmt.Println()
 	}
-	return nil
+}
+
+func printDatabaseStats(w io.Writer, s *statsOutput) {
+	fmt.Fprintf(w, "    clients: %d active, %d waiting | servers: %d active, %d idle | queries: %d (avg %s)\n",
+		s.ClientsActive, s.ClientsWaiting, s.ServersActive, s.ServersIdle, s.TotalQueries, time.Duration(s.AvgQueryTimeUs)*time.Microsecond)
+	if process.Verbose && len(s.Applications) > 0 {
+		fmt.Fprintf(w, "    applications: %s\n", strings.Join(s.Applications, ", "))
+	}
+}
+
+func init() {
+	supportStructuredOutput(listCmd)
 }
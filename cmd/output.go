@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats are the values of the --output flag
+var outputFormats = []string{"text", "json", "yaml"}
+
+var outputFormat = "text"
+
+// structuredOutputAnnotation marks the commands that support json and yaml output
+const structuredOutputAnnotation = "structured-output"
+
+// supportStructuredOutput marks cmd as writing its output with writeOutput
+func supportStructuredOutput(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[structuredOutputAnnotation] = "true"
+}
+
+// validateOutputFormat checks --output, which commands without structured
+// output only accept as text
+func validateOutputFormat(cmd *cobra.Command) error {
+	if !slices.Contains(outputFormats, outputFormat) {
+		return fmt.Errorf("invalid output format %q, must be one of %s", outputFormat, strings.Join(outputFormats, ", "))
+	}
+	if outputFormat != "text" && cmd.Annotations[structuredOutputAnnotation] == "" {
+		return fmt.Errorf("%s does not support --output %s, only text", cmd.CommandPath(), outputFormat)
+	}
+	return nil
+}
+
+// writeOutput writes v to the command's output as JSON or YAML, or calls text
+// to print it for humans
+func writeOutput(cmd *cobra.Command, v any, text func(w io.Writer)) error {
+	w := cmd.OutOrStdout()
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		return enc.Close()
+	default:
+		text(w)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pgboundary/config"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func TestListOutput(t *testing.T) {
+	Cfg = &config.Config{
+		PgBouncer: config.PgBouncerConfig{PidFile: filepath.Join(t.TempDir(), "pgbouncer.pid"), ListenPort: 6432},
+		Scopes:    config.ScopesConfig{Auth: "global"},
+		Targets: map[string]config.Target{
+			"stage": {Host: "https://boundary.example.com", Target: "db", Scope: "stage"},
+			"dev":   {Host: "https://boundary.example.com", Target: "db", Scope: "dev", Database: "app", Tags: []string{"dev"}},
+		},
+	}
+	t.Cleanup(func() { outputFormat = "text" })
+
+	want := listOutput{
+		statusOutput: statusOutput{
+			PgBouncer:   pgBouncerOutput{ListenPort: 6432},
+			Connections: []connectionOutput{},
+		},
+		Targets: []targetOutput{
+			{Name: "dev", Host: "https://boundary.example.com", Target: "db", AuthScope: "global", TargetScope: "dev", Database: "app", Tags: []string{"dev"}},
+			{Name: "stage", Host: "https://boundary.example.com", Target: "db", AuthScope: "global", TargetScope: "stage"},
+		},
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			outputFormat = format
+			var buf bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetOut(&buf)
+			if err := runList(cmd, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var got listOutput
+			var err error
+			if format == "json" {
+				err = json.Unmarshal(buf.Bytes(), &got)
+			} else {
+				err = yaml.Unmarshal(buf.Bytes(), &got)
+			}
+			if err != nil {
+				t.Fatalf("invalid %s: %v\n%s", format, err, buf.String())
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("list output =\n%s\nwant\n%s", gotJSON, wantJSON)
+			}
+		})
+	}
+
+	outputFormat = "text"
+	var buf bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&buf)
+	if err := runList(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Available boundary targets:\n  dev:\n") {
+		t.Errorf("text output =\n%s", buf.String())
+	}
+}
+
+func TestVersionOutput(t *testing.T) {
+	lookupCLIVersion = func(name string, args ...string) componentVersion {
+		if name == "pgbouncer" {
+			return componentVersion{Installed: true, Version: "1.24.1", details: "PgBouncer 1.24.1"}
+		}
+		return componentVersion{}
+	}
+	t.Cleanup(func() {
+		outputFormat = "text"
+		lookupCLIVersion = cliVersion
+	})
+	outputFormat = "json"
+
+	var buf bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&buf)
+	if err := versionCmd.RunE(cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "pgboundary": {
+    "installed": true,
+    "version": "dev",
+    "commit": "none",
+    "build_date": "unknown"
+  },
+  "boundary": {
+    "installed": false
+  },
+  "pgbouncer": {
+    "installed": true,
+    "version": "1.24.1"
+  }
+}
+`
+	if buf.String() != want {
+		t.Errorf("version output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestOutputFlag(t *testing.T) {
+	t.Cleanup(func() { outputFormat = "text" })
+
+	for _, cmd := range rootCmd.Commands() {
+		supported := cmd == listCmd || cmd == statusCmd || cmd == versionCmd
+		for _, format := range outputFormats {
+			outputFormat = format
+			err := validateOutputFormat(cmd)
+			if want := format == "text" || supported; (err == nil) != want {
+				t.Errorf("%s --output %s: err = %v, want accepted = %v", cmd.Name(), format, err, want)
+			}
+		}
+	}
+
+	outputFormat = "xml"
+	if err := validateOutputFormat(listCmd); err == nil {
+		t.Error("invalid output format accepted")
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"pgboundary/internal/process"
+)
+
+// rollback collects the undo actions of a multi-step operation, so a failure or
+// interrupt leaves nothing behind
+type rollback struct {
+	steps []undoStep
+}
+
+type undoStep struct {
+	name string
+	undo func() error
+}
+
+// add registers the undo action of a step that has completed
+func (r *rollback) add(name string, undo func() error) {
+	r.steps = append(r.steps, undoStep{name: name, undo: undo})
+}
+
+// run undoes all registered steps in reverse order and returns err together
+// with any errors of the undo actions
+func (r *rollback) run(err error) error {
+	errs := []error{err}
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "rolling back: %s\n", step.name)
+		}
+		if undoErr := step.undo(); undoErr != nil {
+			errs = append(errs, fmt.Errorf("failed to %s: %w", step.name, undoErr))
+		}
+	}
+	r.steps = nil
+	return errors.Join(errs...)
+}
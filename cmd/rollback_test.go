@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	var undone []string
+	var undo rollback
+	undo.add("stop process", func() error {
+		undone = append(undone, "stop process")
+		return nil
+	})
+	cleanupErr := errors.New("file busy")
+	undo.add("remove file", func() error {
+		undone = append(undone, "remove file")
+		return cleanupErr
+	})
+
+	origErr := errors.New("reload failed")
+	err := undo.run(origErr)
+
+	if !slices.Equal(undone, []string{"remove file", "stop process"}) {
+		t.Errorf("undo order = %v, want [remove file stop process]", undone)
+	}
+	if !errors.Is(err, origErr) || !errors.Is(err, cleanupErr) {
+		t.Errorf("run() = %v, want original and cleanup errors", err)
+	}
+
+	// Steps are only undone once
+	undone = nil
+	if err := undo.run(origErr); !errors.Is(err, origErr) || len(undone) != 0 {
+		t.Errorf("second run() = %v, undone %v", err, undone)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"pgboundary/config"
 	"pgboundary/internal/process"
@@ -25,6 +26,9 @@ var rootCmd = &cobra.Command{
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Set verbose flag for all commands
 		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+		if err := validateOutputFormat(cmd); err != nil {
+			return err
+		}
 
 		var err error
 		if configFile != "" {
@@ -66,7 +70,7 @@ func loadConfigFromDefaultLocations() (*config.Config, error) {
 		conf, err := config.LoadConfig(location)
 		if err == nil {
 			if process.Verbose {
-				fmt.Printf("Using configuration file: %s\n", location)
+				fmt.Fprintf(os.Stderr, "Using configuration file: %s\n", location)
 			}
 			return conf, nil
 		}
@@ -85,6 +89,7 @@ func Execute() error {
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "config file (default: ./pgboundary.ini, ~/.pgboundary/pgboundary.ini, or $XDG_CONFIG_HOME/pgboundary/pgboundary.ini)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "output format: "+strings.Join(outputFormats, ", "))
 
-	rootCmd.AddCommand(listCmd, connectCmd, shutdownCmd, versionCmd)
+	rootCmd.AddCommand(listCmd, connectCmd, shutdownCmd, versionCmd, authCmd, discoverCmd, usersCmd, gcCmd, execCmd, envCmd, exportCmd, statusCmd)
 }
@@ -16,7 +16,11 @@ var shutdownCmd = &cobra.Command{
 	Short: "Shutdown all or specific connections",
 	Long: `Shutdown connections to boundary and pgbouncer.
 If a connection name is provided, only that connection will be shutdown.
-Without arguments, all connections will be shutdown.`,
+Without arguments, all connections will be shutdown.
+
+Only boundary processes started by pgboundary are terminated. Use
+--all-boundary-processes to terminate every boundary process on the machine
+(except "boundary cache"), including those of the Desktop client.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runShutdown,
 	PreRun: func(cmd *cobra.Command, args []string) {
@@ -26,23 +30,37 @@ Without arguments, all connections will be shutdown.`,
 
 func runShutdown(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
-		// Full shutdown - existing behavior
+		allProcesses, _ := cmd.Flags().GetBool("all-boundary-processes")
+
 		if err := pgbouncer.Shutdown(Cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 
-		if err := boundary.Shutdown(); err != nil {
+		// Clear the config before ending sessions, so watching supervisors stop.
+		// Only the sessions removed from the registry are ended, including any
+		// connected in the meantime.
+		sessions, err := pgbouncer.ClearSessions(Cfg)
+		if err != nil {
+			return fmt.Errorf("failed to clean pgbouncer config: %w", err)
+		}
+
+		if err := boundary.Shutdown(sessions); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 
-		if err := pgbouncer.CleanConfig(Cfg); err != nil {
-			return fmt.Errorf("failed to clean pgbouncer config: %w", err)
+		if allProcesses {
+			if err := boundary.ShutdownAll(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
 		}
 
 		return nil
 	}
 
 	// Selective shutdown
+	if allProcesses, _ := cmd.Flags().GetBool("all-boundary-processes"); allProcesses {
+		return fmt.Errorf("--all-boundary-processes can only be used without a connection name")
+	}
 	connection := args[0]
 	if err := pgbouncer.ShutdownConnection(Cfg, connection); err != nil {
 		return fmt.Errorf("failed to shutdown connection %s: %w", connection, err)
@@ -50,3 +68,7 @@ func runShutdown(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func init() {
+	shutdownCmd.Flags().Bool("all-boundary-processes", false, "terminate all boundary processes on the machine, not only those started by pgboundary")
+}
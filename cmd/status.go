@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether pgbouncer is running and the active connections",
+	Args:  cobra.NoArgs,
+	RunE:  runStatus,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	status, err := collectStatus()
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(cmd, status, func(w io.Writer) {
+		if !status.PgBouncer.Running {
+			fmt.Fprintln(w, "PgBouncer is not running")
+			return
+		}
+		fmt.Fprintf(w, "PgBouncer is running (pid: %d, port: %d)\n", status.PgBouncer.Pid, status.PgBouncer.ListenPort)
+		if len(status.Connections) == 0 {
+			fmt.Fprintln(w, "No active connections")
+			return
+		}
+		fmt.Fprintln(w, "Active PgBouncer connections:")
+		printConnections(w, status)
+	})
+}
+
+func init() {
+	supportStructuredOutput(statusCmd)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage the local users of pgbouncer",
+	Long: `Manage the users your IDE or database tool authenticates with at pgbouncer.
+Users are stored in the auth_file of the pgbouncer config with SCRAM-SHA-256
+verifiers instead of plaintext passwords.`,
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users of the auth_file",
+	Args:  cobra.NoArgs,
+	RunE:  runUsersList,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+var usersAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a user or change its password",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUsersAdd,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+var usersRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUsersRemove,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		process.Verbose, _ = cmd.Flags().GetBool("verbose")
+	},
+}
+
+func runUsersList(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	authFile, err := authFilePath()
+	if err != nil {
+		return err
+	}
+
+	users, err := pgbouncer.ReadAuthUsers(authFile)
+	if err != nil {
+		return err
+	}
+	printAuthFileWarnings(authFile)
+
+	if len(users) == 0 {
+		fmt.Fprintln(out, "No users")
+		return nil
+	}
+	fmt.Fprintln(out, "Users:")
+	for _, user := range users {
+		fmt.Fprintf(out, "  %s (%s)\n", user.Name, user.Kind())
+	}
+	if process.Verbose {
+		fmt.Fprintf(out, "Auth file: %s\n", authFile)
+	}
+	return nil
+}
+
+func runUsersAdd(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	authFile, err := authFilePath()
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	if name == "" || strings.ContainsAny(name, "\n\r") {
+		return fmt.Errorf("invalid user name %q", name)
+	}
+
+	passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+	password, err := readNewPassword(name, passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := pgbouncer.ScramVerifier(password)
+	if err != nil {
+		return err
+	}
+	replaced, err := pgbouncer.PutAuthUser(authFile, pgbouncer.AuthUser{Name: name, Secret: verifier})
+	if err != nil {
+		return err
+	}
+	if replaced {
+		fmt.Fprintf(out, "Updated password of user %q\n", name)
+	} else {
+		fmt.Fprintf(out, "Added user %q\n", name)
+	}
+
+	printAuthFileWarnings(authFile)
+	return applyAuthFile()
+}
+
+func runUsersRemove(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	authFile, err := authFilePath()
+	if err != nil {
+		return err
+	}
+
+	removed, err := pgbouncer.RemoveAuthUser(authFile, args[0])
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("user %q not found in %s", args[0], authFile)
+	}
+	fmt.Fprintf(out, "Removed user %q\n", args[0])
+
+	printAuthFileWarnings(authFile)
+	return applyAuthFile()
+}
+
+func authFilePath() (string, error) {
+	if Cfg.PgBouncer.AuthFile == "" {
+		return "", fmt.Errorf("no auth_file configured in %s", Cfg.PgBouncer.ConfFile)
+	}
+	return Cfg.PgBouncer.AuthFile, nil
+}
+
+func printAuthFileWarnings(authFile string) {
+	for _, warning := range pgbouncer.AuthFileWarnings(authFile) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+// applyAuthFile renders the auth_type matching the auth_file and makes a
+// running pgbouncer pick up the changes
+func applyAuthFile() error {
+	if err := pgbouncer.RenderConfig(Cfg); err != nil {
+		return err
+	}
+	if running, _, err := pgbouncer.CheckStatus(Cfg.PgBouncer.PidFile); err != nil || !running {
+		return nil
+	}
+	if err := pgbouncer.Reload(Cfg); err != nil {
+		return fmt.Errorf("failed to reload pgbouncer: %w", err)
+	}
+	return nil
+}
+
+// readNewPassword reads the password from stdin or prompts for it twice
+func readNewPassword(name string, fromStdin bool) (string, error) {
+	if fromStdin {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return "", errors.New("password must not be empty")
+		}
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no terminal available to prompt for the password; use --password-stdin")
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", name)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if len(password) == 0 {
+		return "", errors.New("password must not be empty")
+	}
+
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	repeated, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if string(password) != string(repeated) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(password), nil
+}
+
+func init() {
+	usersAddCmd.Flags().Bool("password-stdin", false, "read the password from stdin instead of prompting")
+	usersCmd.AddCommand(usersListCmd, usersAddCmd, usersRemoveCmd)
+}
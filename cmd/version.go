@@ -3,7 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -32,7 +34,7 @@ func getLatestRelease(owner, repo string) (*GitHubRelease, error) {
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("failed to close response body: %v\n", err)
+			fmt.Fprintf(os.Stderr, "failed to close response body: %v\n", err)
 		}
 	}()
 
@@ -47,62 +49,121 @@ func getLatestRelease(owner, repo string) (*GitHubRelease, error) {
 	return &release, nil
 }
 
+// versionOutput is the schema of `version --output json|yaml`
+type versionOutput struct {
+	Pgboundary componentVersion `json:"pgboundary" yaml:"pgboundary"`
+	Boundary   componentVersion `json:"boundary" yaml:"boundary"`
+	PgBouncer  componentVersion `json:"pgbouncer" yaml:"pgbouncer"`
+}
+
+type componentVersion struct {
+	Installed bool   `json:"installed" yaml:"installed"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	Commit    string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty" yaml:"build_date,omitempty"`
+	// Latest is only looked up with --verbose
+	Latest *latestRelease `json:"latest,omitempty" yaml:"latest,omitempty"`
+	// details is the full version output of the CLI, shown in text output
+	details string
+}
+
+type latestRelease struct {
+	Version string `json:"version" yaml:"version"`
+	URL     string `json:"url" yaml:"url"`
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
 	Long:  `Print detailed version information about pgboundary and its dependencies`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		verbose, _ := cmd.Flags().GetBool("verbose")
 
-		// PgBoundary version
-		fmt.Printf("pgboundary:\n")
-		fmt.Printf(" %s (commit: %s, built: %s)\n", version, commit, buildDate)
+		output := versionOutput{
+			Pgboundary: componentVersion{
+				Installed: true,
+				Version:   version,
+				Commit:    commit,
+				BuildDate: buildDate,
+				details:   fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, buildDate),
+			},
+			Boundary:  lookupCLIVersion("boundary", "version"),
+			PgBouncer: lookupCLIVersion("pgbouncer", "--version"),
+		}
 		if verbose {
-			if release, err := getLatestRelease("sigterm-de", "pgboundary"); err == nil {
-				fmt.Printf(" Latest: %s (%s)\n", release.TagName, release.HTMLURL)
-			} else {
-				fmt.Printf(" Latest: not found\n")
+			output.Pgboundary.Latest = latest("sigterm-de", "pgboundary")
+			if output.Boundary.Installed {
+				output.Boundary.Latest = latest("hashicorp", "boundary")
+			}
+			if output.PgBouncer.Installed {
+				output.PgBouncer.Latest = latest("pgbouncer", "pgbouncer")
 			}
 		}
 
-		// Boundary version
-		fmt.Printf("\nBoundary CLI:\n")
-		boundaryCmd := exec.Command("boundary", "version")
-		if output, err := boundaryCmd.Output(); err == nil {
-			ver := strings.TrimSpace(string(output))
-			fmt.Printf(" %s\n", ver)
-			if verbose {
-				if release, err := getLatestRelease("hashicorp", "boundary"); err == nil {
-					fmt.Printf(" Latest: %s (%s)\n", release.TagName, release.HTMLURL)
-				} else {
-					fmt.Printf(" Latest: not found\n")
-				}
-			}
-		} else {
-			fmt.Printf(" Current: not found\n")
-			if verbose {
-				fmt.Printf(" Latest: not available\n")
-			}
+		return writeOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintf(w, "pgboundary:\n")
+			printComponentVersion(w, output.Pgboundary, verbose)
+			fmt.Fprintf(w, "\nBoundary CLI:\n")
+			printComponentVersion(w, output.Boundary, verbose)
+			fmt.Fprintf(w, "\nPgBouncer:\n")
+			printComponentVersion(w, output.PgBouncer, verbose)
+		})
+	},
+}
+
+// lookupCLIVersion is replaced in tests, which must not depend on the installed CLIs
+var lookupCLIVersion = cliVersion
+
+// cliVersion runs a CLI's version command. The version is the value of a
+// "Version Number:" line (boundary) or the last word of the first line (pgbouncer).
+func cliVersion(name string, args ...string) componentVersion {
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return componentVersion{}
+	}
+	details := strings.TrimSpace(string(output))
+
+	ver := ""
+	for i, line := range strings.Split(details, "\n") {
+		line = strings.TrimSpace(line)
+		if _, value, ok := strings.Cut(line, "Version Number:"); ok {
+			ver = strings.TrimSpace(value)
+			break
 		}
+		if fields := strings.Fields(line); i == 0 && len(fields) > 0 {
+			ver = fields[len(fields)-1]
+		}
+	}
+	return componentVersion{Installed: true, Version: ver, details: details}
+}
 
-		// PgBouncer version
-		fmt.Printf("\nPgBouncer:\n")
-		pgbouncerCmd := exec.Command("pgbouncer", "--version")
-		if output, err := pgbouncerCmd.Output(); err == nil {
-			ver := strings.TrimSpace(string(output))
-			fmt.Printf(" %s\n", ver)
-			if verbose {
-				if release, err := getLatestRelease("pgbouncer", "pgbouncer"); err == nil {
-					fmt.Printf(" Latest: %s (%s)\n", release.TagName, release.HTMLURL)
-				} else {
-					fmt.Printf(" Latest: not found\n")
-				}
-			}
+func latest(owner, repo string) *latestRelease {
+	release, err := getLatestRelease(owner, repo)
+	if err != nil {
+		return nil
+	}
+	return &latestRelease{Version: release.TagName, URL: release.HTMLURL}
+}
+
+func printComponentVersion(w io.Writer, v componentVersion, verbose bool) {
+	if !v.Installed {
+		fmt.Fprintf(w, " Current: not found\n")
+		if verbose {
+			fmt.Fprintf(w, " Latest: not available\n")
+		}
+		return
+	}
+
+	fmt.Fprintf(w, " %s\n", v.details)
+	if verbose {
+		if v.Latest != nil {
+			fmt.Fprintf(w, " Latest: %s (%s)\n", v.Latest.Version, v.Latest.URL)
 		} else {
-			fmt.Printf(" Current: not found\n")
-			if verbose {
-				fmt.Printf(" Latest: not available\n")
-			}
+			fmt.Fprintf(w, " Latest: not found\n")
 		}
-	},
+	}
+}
+
+func init() {
+	supportStructuredOutput(versionCmd)
 }
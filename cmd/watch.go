@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"pgboundary/config"
+	"pgboundary/internal/boundary"
+	"pgboundary/internal/pgbouncer"
+	"pgboundary/internal/process"
+	"pgboundary/internal/state"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// watchConnection supervises the boundary session of a target and renews it
+// whenever the boundary process exits, until the target is shut down or the
+// watcher is interrupted
+func watchConnection(ctx context.Context, out io.Writer, target string, targetCfg config.Target, auth config.AuthConfig, authScope, targetScope string, conn *boundary.Connection, maxRetries int) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(out, "Watching target %q (boundary pid: %d), press Ctrl-C to stop\n", target, conn.Pid)
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(out, "Stopped watching target %q, the connection stays up until shutdown\n", target)
+			return nil
+		case <-conn.Done:
+		}
+
+		// A shutdown removes the target from the configuration before ending the session
+		connected, err := pgbouncer.IsTargetConnected(Cfg, target)
+		if err != nil {
+			return fmt.Errorf("failed to check target connection status: %w", err)
+		}
+		if !connected {
+			fmt.Fprintf(out, "Target %q was shut down, stopped watching\n", target)
+			return nil
+		}
+
+		fmt.Fprintf(out, "Boundary session for target %q ended, reconnecting\n", target)
+		conn, err = renewConnection(ctx, target, targetCfg, auth, authScope, targetScope, maxRetries)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintf(out, "Stopped watching target %q\n", target)
+				return nil
+			}
+			// Do not leave pgbouncer pointing at a dead port
+			if cleanupErr := pgbouncer.ShutdownConnection(Cfg, target); cleanupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", cleanupErr)
+			}
+			return fmt.Errorf("failed to renew boundary session for target %q: %w", target, err)
+		}
+		fmt.Fprintf(out, "Target %q reconnected (boundary pid: %d)\n", target, conn.Pid)
+	}
+}
+
+// renewConnection starts a new boundary session for target and swaps its
+// pgbouncer database entry over to it, retrying with exponential backoff
+func renewConnection(ctx context.Context, target string, targetCfg config.Target, auth config.AuthConfig, authScope, targetScope string, maxRetries int) (*boundary.Connection, error) {
+	backoff := initialBackoff
+	var lastErr error
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		conn, err := boundary.StartConnection(ctx, targetCfg, authScope, targetScope, auth, Cfg.Boundary.ReadyTimeout)
+		if err == nil {
+			if err = pgbouncer.SwapConnection(Cfg, target, conn); err == nil {
+				return conn, nil
+			}
+			// Do not leak the new session if pgbouncer could not pick it up
+			if shutdownErr := boundary.Shutdown([]state.Session{conn.Session(target)}); shutdownErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", shutdownErr)
+			}
+		}
+		lastErr = err
+
+		fmt.Fprintf(os.Stderr, "Warning: reconnect attempt %d/%d failed: %v\n", attempt, maxRetries, err)
+		if attempt == maxRetries {
+			break
+		}
+
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "retrying in %s\n", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %w", maxRetries, lastErr)
+}
@@ -5,15 +5,23 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"pgboundary/internal/fileutil"
 
 	"gopkg.in/ini.v1"
 )
 
 type Config struct {
+	// Path is the absolute path of the loaded config file
+	Path      string
 	PgBouncer PgBouncerConfig
 	Scopes    ScopesConfig
 	Auth      AuthConfig
+	Boundary  BoundaryConfig
 	Targets   map[string]Target
 }
 
@@ -22,8 +30,22 @@ type PgBouncerConfig struct {
 	ConfFile string
 	PidFile  string
 	AuthFile string
+	// ListenAddr, ListenPort and UnixSocketDir are where pgbouncer, including
+	// its admin console, accepts connections
+	ListenAddr    string
+	ListenPort    int
+	UnixSocketDir string
+	// AdminUsers and StatsUsers may connect to the admin console
+	AdminUsers []string
+	StatsUsers []string
 }
 
+// Defaults of pgbouncer for settings missing from its config
+const (
+	DefaultListenPort    = 6432
+	DefaultUnixSocketDir = "/tmp"
+)
+
 type ScopesConfig struct {
 	Auth   string
 	Target string
@@ -35,12 +57,120 @@ type Target struct {
 	Database string
 	Auth     string
 	Scope    string
+	// Method, LoginName and Password override the [auth] section for this target
+	Method    string
+	LoginName CredentialSource
+	Password  CredentialSource
+	// Pool holds the pgbouncer settings of the target's database entry
+	Pool PoolConfig
+	// Tags group targets, e.g. to connect them together
+	Tags []string
+}
+
+// HasTag reports whether the target is tagged with tag
+func (t Target) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, tag)
+}
+
+// PoolConfig holds pgbouncer per-database settings. Empty fields fall back to
+// the [pgbouncer] section of the pgbouncer config.
+type PoolConfig struct {
+	PoolMode         string
+	PoolSize         string
+	ReservePool      string
+	MaxDbConnections string
+	ClientEncoding   string
+	DateStyle        string
+	TimeZone         string
+}
+
+// poolSettings are the pgbouncer database settings accepted in target entries, in rendering order
+var poolSettings = []string{"pool_mode", "pool_size", "reserve_pool", "max_db_connections", "client_encoding", "datestyle", "timezone"}
+
+var poolModes = []string{"session", "transaction", "statement"}
+
+func (p *PoolConfig) field(key string) *string {
+	switch key {
+	case "pool_mode":
+		return &p.PoolMode
+	case "pool_size":
+		return &p.PoolSize
+	case "reserve_pool":
+		return &p.ReservePool
+	case "max_db_connections":
+		return &p.MaxDbConnections
+	case "client_encoding":
+		return &p.ClientEncoding
+	case "datestyle":
+		return &p.DateStyle
+	case "timezone":
+		return &p.TimeZone
+	}
+	return nil
+}
+
+// Settings returns the configured settings as key/value pairs in a stable order
+func (p PoolConfig) Settings() [][2]string {
+	var settings [][2]string
+	for _, key := range poolSettings {
+		if value := *p.field(key); value != "" {
+			settings = append(settings, [2]string{key, value})
+		}
+	}
+	return settings
+}
+
+func (p PoolConfig) validate() error {
+	if p.PoolMode != "" && !slices.Contains(poolModes, p.PoolMode) {
+		return fmt.Errorf("invalid pool_mode %q, must be one of %s", p.PoolMode, strings.Join(poolModes, ", "))
+	}
+	for _, key := range []string{"pool_size", "reserve_pool", "max_db_connections"} {
+		value := *p.field(key)
+		if value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q, must be a non-negative integer", key, value)
+		}
+	}
+	for _, key := range []string{"client_encoding", "datestyle", "timezone"} {
+		if value := *p.field(key); strings.ContainsAny(value, "'\"\n") {
+			return fmt.Errorf("invalid %s %q, quotes are not allowed", key, value)
+		}
+	}
+	return nil
 }
 
 type AuthConfig struct {
-	Method string
+	Method    string
+	LoginName CredentialSource
+	Password  CredentialSource
 }
 
+// CredentialSource describes where a credential is read from. At most one field
+// is set; if none is, the user is prompted.
+type CredentialSource struct {
+	Value   string
+	Env     string
+	File    string
+	Command string
+}
+
+// IsSet reports whether a non-interactive source is configured
+func (c CredentialSource) IsSet() bool {
+	return c != CredentialSource{}
+}
+
+var authMethods = []string{"oidc", "password", "ldap"}
+
+type BoundaryConfig struct {
+	// ReadyTimeout bounds how long to wait for `boundary connect` to report
+	// a session and for its local proxy to accept connections
+	ReadyTimeout time.Duration
+}
+
+const DefaultReadyTimeout = 30 * time.Second
+
 func LoadConfig(path string) (*Config, error) {
 	cfg := &Config{
 		Targets: make(map[string]Target),
@@ -57,6 +187,7 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
 	configDir := filepath.Dir(absPath)
+	cfg.Path = absPath
 
 	// Load basic configuration
 	cfg.PgBouncer.WorkDir = file.Section("pgbouncer").Key("workdir").String()
@@ -65,11 +196,31 @@ func LoadConfig(path string) (*Config, error) {
 	cfg.Scopes.Target = file.Section("scopes").Key("target").String()
 
 	// Load auth configuration
-	cfg.Auth.Method = file.Section("auth").Key("method").String()
+	authSection := file.Section("auth")
+	settings := make(map[string]string)
+	for _, key := range authSection.Keys() {
+		settings[key.Name()] = key.String()
+	}
+	if err := cfg.Auth.parse(settings, configDir); err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
 	if cfg.Auth.Method == "" {
 		cfg.Auth.Method = "oidc" // default to oidc if not specified
 	}
 
+	// Load boundary configuration
+	cfg.Boundary.ReadyTimeout = DefaultReadyTimeout
+	if key := file.Section("boundary").Key("ready_timeout"); key.String() != "" {
+		timeout, err := time.ParseDuration(key.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid ready_timeout %q: %w", key.String(), err)
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("ready_timeout must be positive (got: %s)", key.String())
+		}
+		cfg.Boundary.ReadyTimeout = timeout
+	}
+
 	// Resolve workdir path
 	if filepath.IsAbs(cfg.PgBouncer.WorkDir) {
 		cfg.PgBouncer.WorkDir = filepath.Clean(cfg.PgBouncer.WorkDir)
@@ -84,6 +235,8 @@ func LoadConfig(path string) (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse target %s: %w", key.Name(), err)
 		}
+		target.LoginName.File = resolvePath(configDir, target.LoginName.File)
+		target.Password.File = resolvePath(configDir, target.Password.File)
 		cfg.Targets[key.Name()] = target
 	}
 
@@ -96,12 +249,87 @@ func LoadConfig(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// TargetAuth returns the authentication settings for a target, with the
+// target's own settings taking precedence over the [auth] section
+func (c *Config) TargetAuth(target Target) AuthConfig {
+	auth := c.Auth
+	if target.Method != "" {
+		auth.Method = target.Method
+	}
+	if target.LoginName.IsSet() {
+		auth.LoginName = target.LoginName
+	}
+	if target.Password.IsSet() {
+		auth.Password = target.Password
+	}
+	return auth
+}
+
+// parse reads auth settings from key/value pairs as found in the [auth]
+// section or a target line
+func (a *AuthConfig) parse(settings map[string]string, configDir string) error {
+	for key, value := range settings {
+		switch key {
+		case "method":
+			a.Method = value
+		case "login_name":
+			a.LoginName.Value = value
+		case "login_name_env":
+			a.LoginName.Env = value
+		case "login_name_file":
+			a.LoginName.File = resolvePath(configDir, value)
+		case "login_name_command":
+			a.LoginName.Command = value
+		case "password_env":
+			a.Password.Env = value
+		case "password_file":
+			a.Password.File = resolvePath(configDir, value)
+		case "password_command":
+			a.Password.Command = value
+		}
+	}
+	return a.validate()
+}
+
+func (a AuthConfig) validate() error {
+	if a.Method != "" && !slices.Contains(authMethods, a.Method) {
+		return fmt.Errorf("unsupported auth method %q (supported: %s)", a.Method, strings.Join(authMethods, ", "))
+	}
+	if err := a.LoginName.validate("login_name"); err != nil {
+		return err
+	}
+	return a.Password.validate("password")
+}
+
+func (c CredentialSource) validate(name string) error {
+	set := 0
+	for _, v := range []string{c.Value, c.Env, c.File, c.Command} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("only one source may be configured for %s", name)
+	}
+	return nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
 func (c *Config) loadPgBouncerConfig(path string) error {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read pgbouncer config: %w", err)
 	}
 
+	c.PgBouncer.ListenPort = DefaultListenPort
+	c.PgBouncer.UnixSocketDir = DefaultUnixSocketDir
+
 	// Parse the file line by line to extract just the values we need
 	lines := strings.Split(string(content), "\n")
 	inPgBouncerSection := false
@@ -135,6 +363,20 @@ func (c *Config) loadPgBouncerConfig(path string) error {
 				c.PgBouncer.PidFile = filepath.Join(c.PgBouncer.WorkDir, value)
 			case "auth_file":
 				c.PgBouncer.AuthFile = filepath.Join(c.PgBouncer.WorkDir, value)
+			case "listen_addr":
+				c.PgBouncer.ListenAddr = value
+			case "listen_port":
+				port, err := strconv.Atoi(value)
+				if err != nil || port <= 0 || port > 65535 {
+					return fmt.Errorf("invalid listen_port %q in pgbouncer config", value)
+				}
+				c.PgBouncer.ListenPort = port
+			case "unix_socket_dir":
+				c.PgBouncer.UnixSocketDir = resolvePath(c.PgBouncer.WorkDir, value)
+			case "admin_users":
+				c.PgBouncer.AdminUsers = splitList(value)
+			case "stats_users":
+				c.PgBouncer.StatsUsers = splitList(value)
 			}
 		}
 	}
@@ -146,11 +388,31 @@ func (c *Config) loadPgBouncerConfig(path string) error {
 	return nil
 }
 
+// splitList splits a comma separated pgbouncer setting
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+var accessSuffix = regexp.MustCompile(`-(?:ro|rw)$`)
+
+// defaultDatabase derives the database name from a target name without "-ro" or "-rw" suffix
+func defaultDatabase(targetName string) string {
+	return accessSuffix.ReplaceAllString(targetName, "")
+}
+
 func parseTarget(value string) (Target, error) {
 	target := Target{}
 
 	// Split the string by spaces and parse key=value pairs
-	for _, part := range strings.Fields(value) {
+	var auth AuthConfig
+	authSettings := make(map[string]string)
+	for _, part := range splitFields(value) {
 		kv := strings.SplitN(part, "=", 2)
 		if len(kv) != 2 {
 			continue
@@ -167,12 +429,33 @@ func parseTarget(value string) (Target, error) {
 			target.Auth = kv[1]
 		case "scope":
 			target.Scope = kv[1]
+		case "tags":
+			target.Tags = splitList(kv[1])
+		case "method", "login_name", "login_name_env", "login_name_file", "login_name_command",
+			"password_env", "password_file", "password_command":
+			authSettings[kv[0]] = kv[1]
+		default:
+			if field := target.Pool.field(kv[0]); field != nil {
+				*field = kv[1]
+			}
 		}
 	}
 
+	if err := target.Pool.validate(); err != nil {
+		return Target{}, err
+	}
+
+	// Paths are resolved relative to the config file by the caller
+	if err := auth.parse(authSettings, ""); err != nil {
+		return Target{}, err
+	}
+	target.Method = auth.Method
+	target.LoginName = auth.LoginName
+	target.Password = auth.Password
+
 	// If database is not explicitly set, derive it from target name
 	if target.Database == "" {
-		target.Database = regexp.MustCompile(`-(?:ro|rw)$`).ReplaceAllString(target.Target, "")
+		target.Database = defaultDatabase(target.Target)
 	}
 
 	// Validate required fields
@@ -192,3 +475,164 @@ func parseTarget(value string) (Target, error) {
 
 	return target, nil
 }
+
+// splitFields splits s around whitespace like strings.Fields, but keeps
+// single or double quoted sections together and strips the quotes
+func splitFields(s string) []string {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	inField := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// FormatTarget renders a target in the `[targets]` line format understood by parseTarget
+func FormatTarget(target Target) string {
+	parts := []string{"host=" + target.Host}
+	if target.Auth != "" {
+		parts = append(parts, "auth="+target.Auth)
+	}
+	parts = append(parts, "target="+target.Target)
+	if target.Scope != "" {
+		parts = append(parts, "scope="+target.Scope)
+	}
+	if target.Database != "" && target.Database != defaultDatabase(target.Target) {
+		parts = append(parts, "database="+target.Database)
+	}
+	if target.Method != "" {
+		parts = append(parts, "method="+target.Method)
+	}
+	for _, cred := range []struct {
+		name   string
+		source CredentialSource
+	}{{"login_name", target.LoginName}, {"password", target.Password}} {
+		for suffix, value := range map[string]string{"": cred.source.Value, "_env": cred.source.Env, "_file": cred.source.File, "_command": cred.source.Command} {
+			if value != "" {
+				parts = append(parts, cred.name+suffix+"="+quoteValue(value))
+			}
+		}
+	}
+	for _, setting := range target.Pool.Settings() {
+		parts = append(parts, setting[0]+"="+quoteValue(setting[1]))
+	}
+	if len(target.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(target.Tags, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteValue(value string) string {
+	if !strings.ContainsAny(value, " \t") {
+		return value
+	}
+	if strings.Contains(value, `"`) {
+		return "'" + value + "'"
+	}
+	return `"` + value + `"`
+}
+
+// AddTargets appends the given targets to the `[targets]` section of the config
+// file at path, leaving the rest of the file untouched. Names that already exist
+// in the file are skipped; the names actually added are returned.
+func AddTargets(path string, names []string, targets map[string]Target) ([]string, error) {
+	file, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	existing := file.Section("targets")
+
+	var added, lines []string
+	for _, name := range names {
+		if existing.HasKey(name) {
+			continue
+		}
+		parsed, err := parseTarget(FormatTarget(targets[name]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %s: %w", name, err)
+		}
+		if parsed.Target != targets[name].Target || parsed.Scope != targets[name].Scope {
+			return nil, fmt.Errorf("invalid target %s: names must not contain whitespace", name)
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", name, FormatTarget(targets[name])))
+		added = append(added, name)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	newContent := insertIntoSection(string(content), "targets", lines)
+	if err := fileutil.WriteFileAtomic(path, []byte(newContent), info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write config file: %w", err)
+	}
+	return added, nil
+}
+
+// insertIntoSection adds lines at the end of an ini section, before any
+// trailing blank lines, creating the section if it does not exist
+func insertIntoSection(content, section string, lines []string) string {
+	fileLines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	header := "[" + section + "]"
+
+	start := -1
+	for i, line := range fileLines {
+		if strings.TrimSpace(line) == header {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return strings.Join(fileLines, "\n") + "\n\n" + header + "\n" + strings.Join(lines, "\n") + "\n"
+	}
+
+	end := len(fileLines)
+	for i := start + 1; i < len(fileLines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(fileLines[i]), "[") {
+			end = i
+			break
+		}
+	}
+	for end > start+1 && strings.TrimSpace(fileLines[end-1]) == "" {
+		end--
+	}
+
+	result := append([]string{}, fileLines[:end]...)
+	result = append(result, lines...)
+	result = append(result, fileLines[end:]...)
+	return strings.Join(result, "\n") + "\n"
+}
@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -28,6 +32,9 @@ app4 = host=https://boundary.example.com target=app1
 	pgbouncerContent := `[pgbouncer]
 pidfile = pgbouncer.pid
 auth_file = userlist.txt
+listen_addr = localhost
+listen_port = 5432
+admin_users = admin, ops
 `
 
 	configPath := filepath.Join(tmpDir, "config.ini")
@@ -56,16 +63,15 @@ auth_file = userlist.txt
 			name: "valid config",
 			path: configPath,
 			want: &Config{
-				PgBouncer: struct {
-					WorkDir  string
-					ConfFile string
-					PidFile  string
-					AuthFile string
-				}{
-					WorkDir:  filepath.Join(tmpDir, "work"),
-					ConfFile: filepath.Join(tmpDir, "work", "pgbouncer.ini"),
-					PidFile:  filepath.Join(tmpDir, "work", "pgbouncer.pid"),
-					AuthFile: filepath.Join(tmpDir, "work", "userlist.txt"),
+				PgBouncer: PgBouncerConfig{
+					WorkDir:       filepath.Join(tmpDir, "work"),
+					ConfFile:      filepath.Join(tmpDir, "work", "pgbouncer.ini"),
+					PidFile:       filepath.Join(tmpDir, "work", "pgbouncer.pid"),
+					AuthFile:      filepath.Join(tmpDir, "work", "userlist.txt"),
+					ListenAddr:    "localhost",
+					ListenPort:    5432,
+					UnixSocketDir: DefaultUnixSocketDir,
+					AdminUsers:    []string{"admin", "ops"},
 				},
 				Scopes: struct {
 					Auth   string
@@ -133,6 +139,16 @@ auth_file = userlist.txt
 			if got.PgBouncer.AuthFile != tt.want.PgBouncer.AuthFile {
 				t.Errorf("AuthFile = %v, want %v", got.PgBouncer.AuthFile, tt.want.PgBouncer.AuthFile)
 			}
+			if got.PgBouncer.ListenAddr != tt.want.PgBouncer.ListenAddr || got.PgBouncer.ListenPort != tt.want.PgBouncer.ListenPort {
+				t.Errorf("listen = %s:%d, want %s:%d", got.PgBouncer.ListenAddr, got.PgBouncer.ListenPort,
+					tt.want.PgBouncer.ListenAddr, tt.want.PgBouncer.ListenPort)
+			}
+			if got.PgBouncer.UnixSocketDir != tt.want.PgBouncer.UnixSocketDir {
+				t.Errorf("UnixSocketDir = %v, want %v", got.PgBouncer.UnixSocketDir, tt.want.PgBouncer.UnixSocketDir)
+			}
+			if !slices.Equal(got.PgBouncer.AdminUsers, tt.want.PgBouncer.AdminUsers) {
+				t.Errorf("AdminUsers = %v, want %v", got.PgBouncer.AdminUsers, tt.want.PgBouncer.AdminUsers)
+			}
 
 			// Compare targets
 			if len(got.Targets) != len(tt.want.Targets) {
@@ -144,7 +160,7 @@ auth_file = userlist.txt
 					t.Errorf("target %s not found", name)
 					continue
 				}
-				if gotTarget != target {
+				if !reflect.DeepEqual(gotTarget, target) {
 					t.Errorf("target %s = %+v, want %+v", name, gotTarget, target)
 				}
 			}
@@ -206,6 +222,68 @@ func TestParseTarget(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:  "target with auth overrides",
+			key:   "app5",
+			value: `host=https://boundary.example.com target=app5-ro method=password login_name_env=BOUNDARY_USER password_command="pass show boundary/app5"`,
+			want: Target{
+				Host:      "https://boundary.example.com",
+				Target:    "app5-ro",
+				Database:  "app5",
+				Method:    "password",
+				LoginName: CredentialSource{Env: "BOUNDARY_USER"},
+				Password:  CredentialSource{Command: "pass show boundary/app5"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "target with pool settings",
+			key:   "reporting",
+			value: `host=https://boundary.example.com target=reporting-ro pool_mode=transaction pool_size=5 reserve_pool=0 max_db_connections=10 datestyle="ISO, MDY" timezone=UTC tags=reporting,prod`,
+			want: Target{
+				Host:     "https://boundary.example.com",
+				Target:   "reporting-ro",
+				Database: "reporting",
+				Pool: PoolConfig{
+					PoolMode:         "transaction",
+					PoolSize:         "5",
+					ReservePool:      "0",
+					MaxDbConnections: "10",
+					DateStyle:        "ISO, MDY",
+					TimeZone:         "UTC",
+				},
+				Tags: []string{"reporting", "prod"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid pool mode",
+			key:     "invalid5",
+			value:   "host=https://boundary.example.com target=app1-ro pool_mode=batch",
+			want:    Target{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pool size",
+			key:     "invalid6",
+			value:   "host=https://boundary.example.com target=app1-ro pool_size=-1",
+			want:    Target{},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported auth method",
+			key:     "invalid3",
+			value:   "host=https://boundary.example.com target=app1-ro method=kerberos",
+			want:    Target{},
+			wantErr: true,
+		},
+		{
+			name:    "multiple password sources",
+			key:     "invalid4",
+			value:   "host=https://boundary.example.com target=app1-ro password_env=PW password_file=pw.txt",
+			want:    Target{},
+			wantErr: true,
+		},
 		{
 			name:    "missing host",
 			key:     "invalid1",
@@ -232,9 +310,180 @@ func TestParseTarget(t *testing.T) {
 			if tt.wantErr {
 				return
 			}
-			if got != tt.want {
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseTarget() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestLoadConfigReadyTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	pgbouncerPath := filepath.Join(tmpDir, "pgbouncer.ini")
+	if err := os.WriteFile(pgbouncerPath, []byte("[pgbouncer]\npidfile = pgbouncer.pid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		section string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: DefaultReadyTimeout,
+		},
+		{
+			name:    "custom",
+			section: "[boundary]\nready_timeout = 90s\n",
+			want:    90 * time.Second,
+		},
+		{
+			name:    "invalid",
+			section: "[boundary]\nready_timeout = soon\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			section: "[boundary]\nready_timeout = -5s\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(tmpDir, tt.name+".ini")
+			content := "[pgbouncer]\nworkdir = .\nconffile = pgbouncer.ini\n\n" + tt.section
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadConfig(configPath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Boundary.ReadyTimeout != tt.want {
+				t.Errorf("ReadyTimeout = %v, want %v", got.Boundary.ReadyTimeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	configContent := `[pgbouncer]
+workdir = .
+conffile = pgbouncer.ini
+
+[targets]
+; keep this comment
+app1 = host=https://boundary.example.com target=app1-ro
+
+[auth]
+method = oidc
+`
+	configPath := filepath.Join(tmpDir, "config.ini")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "pgbouncer.ini"), []byte("[pgbouncer]\npidfile = pgbouncer.pid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := map[string]Target{
+		"app1": {Host: "https://boundary.example.com", Target: "app1-ro"},
+		"app2": {Host: "https://boundary.example.com", Target: "app2-rw", Scope: "dev"},
+		"app3": {Host: "https://boundary.example.com", Target: "app3", Auth: "org", Database: "custom_db"},
+	}
+	added, err := AddTargets(configPath, []string{"app1", "app2", "app3"}, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || added[0] != "app2" || added[1] != "app3" {
+		t.Errorf("AddTargets() added %v, want [app2 app3]", added)
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "; keep this comment") {
+		t.Errorf("comment was not preserved:\n%s", content)
+	}
+	if !strings.Contains(string(content), "app2 = host=https://boundary.example.com target=app2-rw scope=dev\n") {
+		t.Errorf("app2 line missing:\n%s", content)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Target{
+		"app1": {Host: "https://boundary.example.com", Target: "app1-ro", Database: "app1"},
+		"app2": {Host: "https://boundary.example.com", Target: "app2-rw", Scope: "dev", Database: "app2"},
+		"app3": {Host: "https://boundary.example.com", Target: "app3", Auth: "org", Database: "custom_db"},
+	}
+	for name, target := range want {
+		if got := cfg.Targets[name]; !reflect.DeepEqual(got, target) {
+			t.Errorf("target %s = %+v, want %+v", name, got, target)
+		}
+	}
+	if cfg.Auth.Method != "oidc" {
+		t.Errorf("auth section was damaged, method = %q", cfg.Auth.Method)
+	}
+}
+
+func TestTargetAuth(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	configContent := `[pgbouncer]
+workdir = .
+conffile = pgbouncer.ini
+
+[auth]
+method = ldap
+login_name = svc-reporting
+password_file = secrets/boundary.txt
+
+[targets]
+app1 = host=https://boundary.example.com target=app1-ro
+app2 = host=https://boundary.example.com target=app2-ro method=password password_command='pass show "boundary dev"'
+`
+	configPath := filepath.Join(tmpDir, "config.ini")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "pgbouncer.ini"), []byte("[pgbouncer]\npidfile = pgbouncer.pid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := AuthConfig{
+		Method:    "ldap",
+		LoginName: CredentialSource{Value: "svc-reporting"},
+		Password:  CredentialSource{File: filepath.Join(tmpDir, "secrets", "boundary.txt")},
+	}
+	if got := cfg.TargetAuth(cfg.Targets["app1"]); got != want {
+		t.Errorf("TargetAuth(app1) = %+v, want %+v", got, want)
+	}
+
+	want = AuthConfig{
+		Method:    "password",
+		LoginName: CredentialSource{Value: "svc-reporting"},
+		Password:  CredentialSource{Command: `pass show "boundary dev"`},
+	}
+	if got := cfg.TargetAuth(cfg.Targets["app2"]); got != want {
+		t.Errorf("TargetAuth(app2) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package boundary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"pgboundary/config"
+	"pgboundary/internal/process"
+
+	"github.com/hashicorp/boundary/api"
+	"github.com/hashicorp/boundary/api/authmethods"
+	"github.com/hashicorp/boundary/api/authtokens"
+)
+
+const (
+	// oidcTimeout bounds how long we wait for the user to finish the browser login
+	oidcTimeout  = 5 * time.Minute
+	oidcInterval = 1500 * time.Millisecond
+)
+
+// AuthError is returned when the controller rejects an authentication attempt
+type AuthError struct {
+	Method  string
+	Status  int
+	Kind    string
+	Message string
+	err     error
+}
+
+func (e *AuthError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.err.Error()
+	}
+	return fmt.Sprintf("%s authentication failed (%s, status %d): %s", e.Method, e.Kind, e.Status, msg)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.err
+}
+
+func newAuthError(method string, err error) error {
+	apiErr := api.AsServerError(err)
+	if apiErr == nil {
+		return fmt.Errorf("%s authentication failed: %w", method, err)
+	}
+
+	authErr := &AuthError{Method: method, Kind: apiErr.Kind, Message: apiErr.Message, err: err}
+	if resp := apiErr.Response(); resp != nil {
+		authErr.Status = resp.StatusCode()
+	}
+	return authErr
+}
+
+// authenticate obtains a new auth token from the controller
+func authenticate(ctx context.Context, client *api.Client, authMethodId, loginName string, auth config.AuthConfig) (*authtokens.AuthToken, error) {
+	switch auth.Method {
+	case "oidc":
+		return authenticateOidc(ctx, client, authMethodId)
+	case "password", "ldap":
+		return authenticateLogin(ctx, client, authMethodId, loginName, auth)
+	default:
+		return nil, fmt.Errorf("unsupported auth method %q (supported: oidc, password, ldap)", auth.Method)
+	}
+}
+
+// authenticateOidc runs the OIDC flow: the controller hands out an auth URL for
+// the browser and completes the callback itself, while we poll for the token
+func authenticateOidc(parent context.Context, client *api.Client, authMethodId string) (*authtokens.AuthToken, error) {
+	ctx, cancel := context.WithTimeout(parent, oidcTimeout)
+	defer cancel()
+
+	authClient := authmethods.NewClient(client)
+	result, err := authClient.Authenticate(ctx, authMethodId, "start", nil)
+	if err != nil {
+		return nil, newAuthError("oidc", err)
+	}
+
+	var start authmethods.OidcAuthMethodAuthenticateStartResponse
+	if err := json.Unmarshal(result.GetRawAttributes(), &start); err != nil {
+		return nil, fmt.Errorf("failed to parse oidc start response: %w", err)
+	}
+	if start.AuthUrl == "" || start.TokenId == "" {
+		return nil, fmt.Errorf("oidc start response is missing auth url or token id")
+	}
+
+	fmt.Fprintf(os.Stderr, "Opening browser to complete authentication:\n  %s\n", start.AuthUrl)
+	if err := openBrowser(start.AuthUrl); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open a browser (%v), please open the URL manually\n", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, oidcDone(parent)
+		case <-time.After(oidcInterval):
+		}
+
+		result, err := authClient.Authenticate(ctx, authMethodId, "token", map[string]any{
+			"token_id": start.TokenId,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, oidcDone(parent)
+			}
+			return nil, newAuthError("oidc", err)
+		}
+		// The controller answers 202 until the browser login has finished
+		if result.GetResponse().StatusCode() == http.StatusAccepted {
+			continue
+		}
+
+		token, err := result.GetAuthToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse oidc token response: %w", err)
+		}
+		return token, nil
+	}
+}
+
+// oidcDone explains why polling for the oidc token stopped early
+func oidcDone(parent context.Context) error {
+	if err := parent.Err(); err != nil {
+		return fmt.Errorf("oidc authentication interrupted: %w", err)
+	}
+	return fmt.Errorf("oidc authentication not completed within %s", oidcTimeout)
+}
+
+// authenticateLogin runs the login command shared by the password and ldap methods
+func authenticateLogin(ctx context.Context, client *api.Client, authMethodId, loginName string, auth config.AuthConfig) (*authtokens.AuthToken, error) {
+	password, err := resolveCredential(ctx, "password", auth.Password, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	result, err := authmethods.NewClient(client).Authenticate(ctx, authMethodId, "login", map[string]any{
+		"login_name": loginName,
+		"password":   password,
+	})
+	if err != nil {
+		return nil, newAuthError(auth.Method, err)
+	}
+
+	token, err := result.GetAuthToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s token response: %w", auth.Method, err)
+	}
+	return token, nil
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if process.Verbose {
+		fmt.Fprintf(os.Stderr, "running %s\n", strings.Join(cmd.Args, " "))
+	}
+	return cmd.Start()
+}
@@ -2,21 +2,22 @@ package boundary
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"pgboundary/config"
 	"pgboundary/internal/process"
+	"pgboundary/internal/state"
 
 	"github.com/hashicorp/boundary/api"
 	"github.com/hashicorp/boundary/api/authmethods"
-	"github.com/hashicorp/boundary/api/scopes"
 )
 
 type Connection struct {
@@ -25,23 +26,48 @@ type Connection struct {
 	Host     string
 	Port     string
 	Pid      int
+	// StartTime is the creation time of the boundary process in ms since the epoch
+	StartTime  int64
+	SessionId  string
+	Expiration time.Time
+	// Controller and TokenId identify the auth token that owns the session
+	Controller string
+	TokenId    string
+	// Done is closed once the boundary connect process exits
+	Done <-chan struct{}
+}
+
+// Session returns the registry record of the connection for a target
+func (c *Connection) Session(target string) state.Session {
+	return state.Session{
+		Target:            target,
+		BoundaryPid:       c.Pid,
+		BoundaryStartTime: c.StartTime,
+		ProxyHost:         c.Host,
+		ProxyPort:         c.Port,
+		Username:          c.Username,
+		SessionId:         c.SessionId,
+		Expiration:        c.Expiration,
+		Controller:        c.Controller,
+		TokenId:           c.TokenId,
+	}
 }
 
 const defaultTimeout = 45 * time.Second
 
-func getPrimaryAuthMethodId(client *api.Client, scopeId string, preferredMethod string) (string, error) {
+func getPrimaryAuthMethodId(ctx context.Context, client *api.Client, scopeId string, preferredMethod string) (string, error) {
 	authMethodClient := authmethods.NewClient(client)
 
 	// List auth methods in the scope
-	result, err := authMethodClient.List(context.Background(), scopeId)
+	result, err := authMethodClient.List(ctx, scopeId)
 	if err != nil {
 		return "", fmt.Errorf("failed to list auth methods: %w", err)
 	}
 
 	if process.Verbose {
-		fmt.Printf("Found %d auth methods in scope %s:\n", len(result.Items), scopeId)
+		fmt.Fprintf(os.Stderr, "Found %d auth methods in scope %s:\n", len(result.Items), scopeId)
 		for _, method := range result.Items {
-			fmt.Printf("  - ID: %s, Type: %s, Name: %s\n", method.Id, method.Type, method.Name)
+			fmt.Fprintf(os.Stderr, "  - ID: %s, Type: %s, Name: %s\n", method.Id, method.Type, method.Name)
 		}
 	}
 
@@ -49,7 +75,7 @@ func getPrimaryAuthMethodId(client *api.Client, scopeId string, preferredMethod
 	for _, method := range result.Items {
 		if method.Type == preferredMethod {
 			if process.Verbose {
-				fmt.Printf("Selected %s auth method: %s\n", preferredMethod, method.Id)
+				fmt.Fprintf(os.Stderr, "Selected %s auth method: %s\n", preferredMethod, method.Id)
 			}
 			return method.Id, nil
 		}
@@ -58,107 +84,163 @@ func getPrimaryAuthMethodId(client *api.Client, scopeId string, preferredMethod
 	return "", fmt.Errorf("no %s auth method found in scope %s", preferredMethod, scopeId)
 }
 
-func StartConnection(target config.Target, authScope, targetScope, authMethod string) (*Connection, error) {
-	// Initialize the client
+func newClient(host string) (*api.Client, error) {
 	client, err := api.NewClient(nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create boundary client: %w", err)
 	}
-	err = client.SetAddr(target.Host)
-	if err != nil {
+	if err := client.SetAddr(host); err != nil {
 		return nil, fmt.Errorf("failed to set boundary address: %w", err)
 	}
+	return client, nil
+}
 
-	// Get scope ID if not global
-	var scopeId string
-	if authScope != "global" {
-		scopeClient := scopes.NewClient(client)
+// Login returns a client for host carrying a valid auth token. A cached token
+// for the same controller, scope, auth method and login name is reused until it
+// expires or the controller rejects it; otherwise a new one is obtained and cached.
+// Canceling ctx aborts a pending browser login or credential prompt.
+func Login(ctx context.Context, host, authScope string, auth config.AuthConfig) (*api.Client, error) {
+	client, _, err := login(ctx, host, authScope, auth)
+	return client, err
+}
+
+// login is Login that also returns the cache entry of the token in use
+func login(ctx context.Context, host, authScope string, auth config.AuthConfig) (*api.Client, *Token, error) {
+	client, err := newClient(host)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-		defer cancel()
+	scopeId, err := ResolveScope(client, authScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get the primary auth method ID
+	authMethodId, err := getPrimaryAuthMethodId(ctx, client, scopeId, auth.Method)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get auth method ID: %w", err)
+	}
+
+	// Tokens of password and ldap logins belong to the user who logged in
+	auth, err = ResolveLoginName(ctx, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	loginName := auth.LoginName.Value
 
-		listResult, err := scopeClient.List(ctx, "global")
+	store := DefaultTokenStore()
+	cached, err := store.Get(host, scopeId, authMethodId, loginName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring token cache: %v\n", err)
+	}
+	if cached != nil {
+		valid, err := validateToken(ctx, client, cached)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list scopes: %w", err)
+			return nil, nil, err
 		}
-
-		for _, scope := range listResult.Items {
-			if scope.Name == authScope {
-				scopeId = scope.Id
-				break
+		if valid {
+			if process.Verbose {
+				fmt.Fprintf(os.Stderr, "Using cached token %s (expires %s)\n", cached.Id, cached.ExpirationTime.Local().Format(time.RFC3339))
 			}
+			client.SetToken(cached.Token)
+			return client, cached, nil
 		}
-		if scopeId == "" {
-			return nil, fmt.Errorf("scope %q not found", authScope)
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "Cached token %s was rejected by the controller\n", cached.Id)
+		}
+		if err := store.Remove(host, scopeId, authMethodId, loginName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove rejected token: %v\n", err)
 		}
-	} else {
-		scopeId = "global"
 	}
 
-	// Get the primary auth method ID
-	authMethodId, err := getPrimaryAuthMethodId(client, scopeId, authMethod)
+	// Authenticate
+	authToken, err := authenticate(ctx, client, authMethodId, loginName, auth)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get auth method ID: %w", err)
+		return nil, nil, err
 	}
-	// Authenticate
-	authCmd := exec.Command("boundary", "authenticate", authMethod,
-		"-scope-id", scopeId,
-		"-auth-method-id", authMethodId,
-		"-addr", target.Host,
-		"-keyring-type", "none",
-		"-format", "json")
 
-	out, err := authCmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("authentication failed: %w", err)
+	token := &Token{
+		Controller:     normalizeAddr(host),
+		ScopeId:        scopeId,
+		AuthMethodId:   authMethodId,
+		LoginName:      loginName,
+		Id:             authToken.Id,
+		Token:          authToken.Token,
+		ExpirationTime: authToken.ExpirationTime,
 	}
+	if err := store.Put(*token); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache token: %v\n", err)
+	}
+
+	client.SetToken(token.Token)
+	return client, token, nil
+}
 
-	var authResp struct {
-		Item struct {
-			Attributes struct {
-				Token string `json:"token"`
-			} `json:"attributes"`
-		} `json:"item"`
+// StartConnection starts `boundary connect` for a target and waits until the session
+// is ready. Canceling ctx aborts the login or waiting and stops the boundary process.
+func StartConnection(ctx context.Context, target config.Target, authScope, targetScope string, auth config.AuthConfig, readyTimeout time.Duration) (*Connection, error) {
+	client, token, err := login(ctx, target.Host, authScope, auth)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(out, &authResp); err != nil {
-		return nil, fmt.Errorf("failed to parse auth response: %w", err)
+
+	args := []string{"connect", "-target-name", target.Target}
+	// Without a target scope, boundary looks the target name up itself
+	if targetScope != "" {
+		targetScopeId, err := ResolveScope(client, targetScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve target scope: %w", err)
+		}
+		args = append(args, "-target-scope-id", targetScopeId)
 	}
 
-	// Create a temporary file for the connection output
+	// Create a temporary directory for the connection output
 	tmpDir, err := os.MkdirTemp("", "boundary-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer func() {
 		if err := os.RemoveAll(tmpDir); err != nil {
-			fmt.Printf("failed to remove temp dir: %v\n", err)
+			fmt.Fprintf(os.Stderr, "failed to remove temp dir: %v\n", err)
 		}
 	}()
 
 	outputFile := filepath.Join(tmpDir, "connection.json")
+	errorFile := filepath.Join(tmpDir, "stderr.log")
 
 	// Start boundary connection in background
-	connectCmd := exec.Command("boundary", "connect",
-		"-target-name", target.Target,
-		"-target-scope-name", targetScope,
+	connectCmd := exec.Command("boundary", append(args,
 		"-addr", target.Host,
 		"-token", "env://BOUNDARY_TOKEN",
-		"-format", "json")
-	connectCmd.Env = append(os.Environ(), "BOUNDARY_TOKEN="+authResp.Item.Attributes.Token)
+		"-format", "json")...)
+	connectCmd.Env = append(os.Environ(), "BOUNDARY_TOKEN="+client.Token())
 
-	// Open output file
+	// The process outlives pgboundary, so its output goes to files rather than pipes
 	output, err := os.Create(outputFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer func() {
 		if err := output.Close(); err != nil {
-			fmt.Printf("failed to close output file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "failed to close output file: %v\n", err)
+		}
+	}()
+
+	stderr, err := os.Create(errorFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr file: %w", err)
+	}
+	defer func() {
+		if err := stderr.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close stderr file: %v\n", err)
 		}
 	}()
 
 	connectCmd.Stdout = output
-	connectCmd.Stderr = os.Stderr
+	connectCmd.Stderr = stderr
+	// Keep the session alive when the terminal interrupts pgboundary
+	connectCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	// Start the process
 	if err := connectCmd.Start(); err != nil {
@@ -168,44 +250,124 @@ func StartConnection(target config.Target, authScope, targetScope, authMethod st
 	// Store the PID
 	boundaryPid := connectCmd.Process.Pid
 
-	// Wait a bit for the connection to be established
-	time.Sleep(3 * time.Second)
+	exited := make(chan struct{})
+	var exitErr error
+	go func() {
+		exitErr = connectCmd.Wait()
+		close(exited)
+	}()
 
-	// Read and parse the output file
-	content, err := os.ReadFile(outputFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read connection output: %w", err)
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	// Wait for the session document and the local proxy to come up
+	info, err := waitForSession(ctx, outputFile, exited)
+	if err == nil && len(info.Credentials) == 0 {
+		err = fmt.Errorf("no credentials found in response")
+	}
+	if err == nil {
+		err = waitForPort(ctx, info.Address, info.Port)
 	}
+	if err != nil {
+		select {
+		case <-exited:
+		default:
+			// Do not leave a half-initialised session behind
+			if killErr := process.KillProcess(boundaryPid); killErr != nil {
+				fmt.Fprintf(os.Stderr, "failed to stop boundary connect: %v\n", killErr)
+			}
+			<-exited
+		}
 
-	var connResp struct {
-		Credentials []struct {
-			Credential struct {
-				Username string `json:"username"`
-				Password string `json:"password"`
-			} `json:"credential"`
-		} `json:"credentials"`
-		Address string `json:"address"`
-		Port    int    `json:"port"`
+		switch {
+		case errors.Is(err, errProcessExited):
+			err = fmt.Errorf("boundary connect exited before the session was ready: %v", exitErr)
+		case errors.Is(err, context.DeadlineExceeded):
+			err = fmt.Errorf("boundary session not ready after %s: %w", readyTimeout, err)
+		case errors.Is(err, context.Canceled):
+			return nil, fmt.Errorf("boundary connect interrupted: %w", err)
+		default:
+			err = fmt.Errorf("failed to establish boundary session: %w", err)
+		}
+		if msg := readStderr(errorFile); msg != "" {
+			err = fmt.Errorf("%w\n%s", err, msg)
+		}
+		return nil, err
 	}
 
-	if err := json.Unmarshal(content, &connResp); err != nil {
-		return nil, fmt.Errorf("failed to parse connection response: %w", err)
+	if process.Verbose {
+		fmt.Fprintf(os.Stderr, "boundary session %s ready on %s:%d (pid: %d)\n", info.SessionId, info.Address, info.Port, boundaryPid)
 	}
 
-	if len(connResp.Credentials) == 0 {
-		return nil, fmt.Errorf("no credentials found in response")
+	startTime, err := process.CreateTime(boundaryPid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
 
 	return &Connection{
-		Username: connResp.Credentials[0].Credential.Username,
-		Password: connResp.Credentials[0].Credential.Password,
-		Host:     connResp.Address,
-		Port:     strconv.Itoa(connResp.Port),
-		Pid:      boundaryPid,
+		Username:   info.Credentials[0].Credential.Username,
+		Password:   info.Credentials[0].Credential.Password,
+		Host:       info.Address,
+		Port:       strconv.Itoa(info.Port),
+		Pid:        boundaryPid,
+		StartTime:  startTime,
+		SessionId:  info.SessionId,
+		Expiration: info.Expiration,
+		Controller: token.Controller,
+		TokenId:    token.Id,
+		Done:       exited,
 	}, nil
 }
 
-func Shutdown() error {
+// Shutdown terminates the boundary processes of the given sessions and cancels the
+// sessions on the controller. A process is only killed if its PID still belongs to
+// the process pgboundary started.
+func Shutdown(sessions []state.Session) error {
+	var errs []error
+	for _, session := range sessions {
+		if OwnsProcess(session) {
+			if process.Verbose {
+				fmt.Fprintf(os.Stderr, "stopping boundary process %d of target %s\n", session.BoundaryPid, session.Target)
+			}
+			if err := process.KillProcess(session.BoundaryPid); err != nil {
+				errs = append(errs, fmt.Errorf("failed to kill boundary process of target %s: %w", session.Target, err))
+			}
+		} else if process.Verbose {
+			fmt.Fprintf(os.Stderr, "boundary process %d of target %s is no longer running\n", session.BoundaryPid, session.Target)
+		}
+
+		// The session may outlive the local process, e.g. if it was killed
+		if session.SessionId == "" {
+			continue
+		}
+		terminated, err := CancelSession(session)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to cancel session of target %s: %w", session.Target, err))
+		case terminated:
+			fmt.Printf("Session %s of target %s was already terminated\n", session.SessionId, session.Target)
+		default:
+			fmt.Printf("Session %s of target %s canceled\n", session.SessionId, session.Target)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OwnsProcess reports whether the boundary process of a session is still running.
+// Sessions recorded without a start time fall back to a process name check.
+func OwnsProcess(session state.Session) bool {
+	if session.BoundaryPid <= 0 {
+		return false
+	}
+	if session.BoundaryStartTime == 0 {
+		return process.IsProcessType(session.BoundaryPid, "boundary")
+	}
+	return process.IsSameProcess(session.BoundaryPid, session.BoundaryStartTime)
+}
+
+// ShutdownAll terminates every boundary process on the machine except `boundary cache`,
+// including processes not started by pgboundary
+func ShutdownAll() error {
 	// Get our own PID to exclude it
 	ownPid := os.Getpid()
 
@@ -229,7 +391,7 @@ func Shutdown() error {
 			cmdline, err := proc.Cmdline()
 			if err == nil && strings.Contains(cmdline, "boundary cache") {
 				if process.Verbose {
-					fmt.Printf("Skipping boundary cache process: %d\n", pid)
+					fmt.Fprintf(os.Stderr, "Skipping boundary cache process: %d\n", pid)
 				}
 				continue
 			}
@@ -0,0 +1,144 @@
+package boundary
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"pgboundary/config"
+	"pgboundary/internal/process"
+
+	"golang.org/x/term"
+)
+
+// commandWaitDelay bounds how long an interrupted credential command may keep
+// its output open, e.g. through a child process of the shell
+const commandWaitDelay = time.Second
+
+// ResolveLoginName returns auth with the login name of a password or ldap login
+// resolved to a value, so its source is only read or prompted for once
+func ResolveLoginName(ctx context.Context, auth config.AuthConfig) (config.AuthConfig, error) {
+	if auth.Method != "password" && auth.Method != "ldap" {
+		return auth, nil
+	}
+	loginName, err := resolveCredential(ctx, "login_name", auth.LoginName, false)
+	if err != nil {
+		return auth, err
+	}
+	auth.LoginName = config.CredentialSource{Value: loginName}
+	return auth, nil
+}
+
+// resolveCredential reads a credential from its configured source, falling back
+// to an interactive prompt when no source is configured. Canceling ctx stops a
+// running credential command or prompt.
+func resolveCredential(ctx context.Context, name string, source config.CredentialSource, secret bool) (string, error) {
+	switch {
+	case source.Value != "":
+		return source.Value, nil
+
+	case source.Env != "":
+		value, ok := os.LookupEnv(source.Env)
+		if !ok || value == "" {
+			return "", fmt.Errorf("environment variable %s for %s is not set", source.Env, name)
+		}
+		return value, nil
+
+	case source.File != "":
+		info, err := os.Stat(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s file: %w", name, err)
+		}
+		if secret && info.Mode().Perm()&0077 != 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s file %s is accessible by other users (mode %s)\n", name, source.File, info.Mode().Perm())
+		}
+		content, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s file: %w", name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+
+	case source.Command != "":
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "running %s command: %s\n", name, source.Command)
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", source.Command)
+		cmd.WaitDelay = commandWaitDelay
+		// Let password managers ask for their passphrase
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("%s command interrupted: %w", name, ctx.Err())
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s command failed: %w", name, err)
+		}
+		value := strings.TrimRight(string(out), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("%s command returned no output", name)
+		}
+		return value, nil
+	}
+
+	return prompt(ctx, name, secret)
+}
+
+// prompt reads a credential from the terminal. Reads cannot be canceled, so on
+// interrupt the terminal is restored and the pending read is abandoned.
+func prompt(ctx context.Context, name string, secret bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal available to prompt for %s; configure %s_env, %s_file or %s_command", name, name, name, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("%s prompt interrupted: %w", name, err)
+	}
+	oldState, err := term.GetState(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get terminal state: %w", err)
+	}
+
+	type result struct {
+		value string
+		err   error
+	}
+	done := make(chan result, 1)
+
+	fmt.Fprintf(os.Stderr, "Boundary %s: ", strings.ReplaceAll(name, "_", " "))
+	go func() {
+		value, err := readLine(fd, secret)
+		done <- result{value, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = term.Restore(fd, oldState)
+		fmt.Fprintln(os.Stderr)
+		return "", fmt.Errorf("%s prompt interrupted: %w", name, ctx.Err())
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", name, r.err)
+		}
+		if r.value == "" {
+			return "", errors.New(name + " must not be empty")
+		}
+		return r.value, nil
+	}
+}
+
+func readLine(fd int, secret bool) (string, error) {
+	if secret {
+		value, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(value), err
+}
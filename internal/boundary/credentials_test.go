@@ -0,0 +1,36 @@
+package boundary
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pgboundary/config"
+)
+
+func TestResolveCredentialCommand(t *testing.T) {
+	value, err := resolveCredential(context.Background(), "password", config.CredentialSource{Command: "echo s3cret"}, true)
+	if err != nil || value != "s3cret" {
+		t.Fatalf("resolveCredential() = %q, %v, want s3cret", value, err)
+	}
+
+	if _, err := resolveCredential(context.Background(), "password", config.CredentialSource{Command: "true"}, true); err == nil {
+		t.Error("resolveCredential() with empty command output succeeded")
+	}
+}
+
+func TestResolveCredentialCommandCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	// The shell keeps running after the sleep, so canceling must not wait for it
+	start := time.Now()
+	_, err := resolveCredential(ctx, "password", config.CredentialSource{Command: "sleep 3; echo s3cret"}, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("resolveCredential() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("resolveCredential() returned after %s, want it to stop on cancel", elapsed)
+	}
+}
@@ -0,0 +1,113 @@
+package boundary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pollInterval = 100 * time.Millisecond
+
+// errProcessExited is returned when `boundary connect` exits before it has
+// written a complete session document
+var errProcessExited = errors.New("boundary connect exited")
+
+// sessionInfo is the document `boundary connect -format json` prints once the
+// session is established
+type sessionInfo struct {
+	Credentials []struct {
+		Credential struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"credential"`
+	} `json:"credentials"`
+	Address    string    `json:"address"`
+	Port       int       `json:"port"`
+	SessionId  string    `json:"session_id"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// followReader reads a file that is still being written to, like `tail -f`.
+// It only reports EOF once the writing process has exited.
+type followReader struct {
+	ctx    context.Context
+	file   *os.File
+	exited <-chan struct{}
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-r.exited:
+			// The process may have written its last bytes right before exiting
+			if n, err := r.file.Read(p); n > 0 || (err != nil && err != io.EOF) {
+				return n, err
+			}
+			return 0, errProcessExited
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// waitForSession parses the stdout file of `boundary connect` incrementally
+// until a complete JSON document is available
+func waitForSession(ctx context.Context, stdoutFile string, exited <-chan struct{}) (*sessionInfo, error) {
+	f, err := os.Open(stdoutFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection output: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close connection output: %v\n", err)
+		}
+	}()
+
+	var info sessionInfo
+	decoder := json.NewDecoder(&followReader{ctx: ctx, file: f, exited: exited})
+	if err := decoder.Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// waitForPort blocks until the local boundary proxy accepts TCP connections
+func waitForPort(ctx context.Context, host string, port int) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: time.Second}
+
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("local proxy %s is not accepting connections: %w", addr, err)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// readStderr returns the captured stderr of the boundary process for error reporting
+func readStderr(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
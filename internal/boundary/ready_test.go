@@ -0,0 +1,112 @@
+package boundary
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sessionDocument = `{"credentials":[{"credential":{"username":"u","password":"p"}}],` +
+	`"address":"127.0.0.1","port":40123,"session_id":"s_1234567890","expiration":"2030-01-02T03:04:05Z"}` + "\n"
+
+func TestWaitForSession(t *testing.T) {
+	tests := []struct {
+		name string
+		// chunks are written one after another with a delay in between
+		chunks  []string
+		exit    bool
+		timeout time.Duration
+		wantErr error
+	}{
+		{name: "single write", chunks: []string{sessionDocument}},
+		{name: "partial writes", chunks: []string{sessionDocument[:10], sessionDocument[10:70], sessionDocument[70:]}},
+		{name: "exit after document", chunks: []string{sessionDocument}, exit: true},
+		{name: "exit before document is complete", chunks: []string{sessionDocument[:40]}, exit: true, wantErr: errProcessExited},
+		{name: "exit without output", exit: true, wantErr: errProcessExited},
+		{name: "deadline", chunks: []string{sessionDocument[:40]}, timeout: 300 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "connection.json")
+			out, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+
+			exited := make(chan struct{})
+			go func() {
+				for i, chunk := range tt.chunks {
+					if i > 0 {
+						time.Sleep(2 * pollInterval)
+					}
+					if _, err := out.WriteString(chunk); err != nil {
+						t.Error(err)
+					}
+				}
+				if tt.exit {
+					close(exited)
+				}
+			}()
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			info, err := waitForSession(ctx, path, exited)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("waitForSession() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.SessionId != "s_1234567890" || info.Port != 40123 || info.Address != "127.0.0.1" ||
+				len(info.Credentials) != 1 || info.Credentials[0].Credential.Username != "u" ||
+				!info.Expiration.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Errorf("waitForSession() = %+v", info)
+			}
+		})
+	}
+
+	if _, err := waitForSession(context.Background(), filepath.Join(t.TempDir(), "missing.json"), nil); err == nil {
+		t.Error("waitForSession() on missing file succeeded")
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := waitForPort(ctx, "127.0.0.1", port); err != nil {
+		t.Errorf("waitForPort() on open port = %v", err)
+	}
+
+	// Nothing listens once the listener is closed
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = waitForPort(ctx, "127.0.0.1", port)
+	if err == nil || !strings.Contains(err.Error(), "127.0.0.1:"+strconv.Itoa(port)+" is not accepting connections") {
+		t.Errorf("waitForPort() on closed port = %v", err)
+	}
+}
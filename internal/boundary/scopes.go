@@ -0,0 +1,170 @@
+package boundary
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/boundary/api"
+	"github.com/hashicorp/boundary/api/scopes"
+)
+
+// scopeNode is a scope as seen in the recursive scope listing
+type scopeNode struct {
+	Id       string
+	Name     string
+	ParentId string
+}
+
+// scopeTree resolves scope references against a recursive scope listing
+type scopeTree map[string]scopeNode
+
+// scopeTrees caches the scope listing per controller and token
+var scopeTrees = struct {
+	sync.Mutex
+	trees map[string]scopeTree
+}{trees: make(map[string]scopeTree)}
+
+// ResolveScope resolves a scope reference to a scope ID. A reference is either
+// "global", a scope ID (o_1234567890, p_1234567890), a slash separated path of scope names
+// below global like "org/dev", or a single scope name that must be unique
+// across all scopes visible to the client.
+func ResolveScope(client *api.Client, ref string) (string, error) {
+	if ref == "" || ref == "global" {
+		return "global", nil
+	}
+	if isScopeId(ref) {
+		return ref, nil
+	}
+
+	tree, err := loadScopeTree(client)
+	if err != nil {
+		return "", err
+	}
+	return tree.resolve(ref)
+}
+
+// scopeIdPattern matches org and project IDs, which Boundary generates with a
+// 10 character alphanumeric suffix
+var scopeIdPattern = regexp.MustCompile(`^[op]_[0-9A-Za-z]{10}$`)
+
+// isScopeId reports whether ref is a scope ID rather than a name like "p_payments"
+func isScopeId(ref string) bool {
+	return scopeIdPattern.MatchString(ref)
+}
+
+func loadScopeTree(client *api.Client) (scopeTree, error) {
+	key := client.Addr() + "\x00" + client.Token()
+
+	scopeTrees.Lock()
+	defer scopeTrees.Unlock()
+	if tree, ok := scopeTrees.trees[key]; ok {
+		return tree, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := scopes.NewClient(client).List(ctx, "global", scopes.WithRecursive(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scopes: %w", err)
+	}
+
+	tree := make(scopeTree, len(result.Items))
+	for _, item := range result.Items {
+		tree[item.Id] = scopeNode{Id: item.Id, Name: item.Name, ParentId: item.ScopeId}
+	}
+	scopeTrees.trees[key] = tree
+	return tree, nil
+}
+
+func (t scopeTree) resolve(ref string) (string, error) {
+	if !strings.Contains(ref, "/") {
+		return t.resolveName(ref)
+	}
+
+	parent := "global"
+	for _, name := range strings.Split(strings.Trim(ref, "/"), "/") {
+		children := t.children(parent, name)
+		switch len(children) {
+		case 0:
+			return "", fmt.Errorf("scope %q not found: no scope named %q in %s", ref, name, t.path(parent))
+		case 1:
+			parent = children[0]
+		default:
+			return "", fmt.Errorf("scope %q is ambiguous: %d scopes named %q in %s (%s)", ref, len(children), name, t.path(parent), strings.Join(children, ", "))
+		}
+	}
+	return parent, nil
+}
+
+// resolveName finds a scope by name anywhere in the tree
+func (t scopeTree) resolveName(name string) (string, error) {
+	var matches []string
+	for id, node := range t {
+		if node.Name == name {
+			matches = append(matches, id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("scope %q not found", name)
+	case 1:
+		return matches[0], nil
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, id := range matches {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", t.path(id), id))
+	}
+	sort.Strings(candidates)
+	return "", fmt.Errorf("scope %q is ambiguous, use a path or ID instead: %s", name, strings.Join(candidates, ", "))
+}
+
+func (t scopeTree) children(parent, name string) []string {
+	var ids []string
+	for id, node := range t {
+		if node.ParentId == parent && node.Name == name {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// path returns the slash separated name path of a scope below global
+func (t scopeTree) path(id string) string {
+	if id == "global" {
+		return "global"
+	}
+
+	var names []string
+	for id != "global" {
+		node, ok := t[id]
+		if !ok {
+			// Parent not visible to us, fall back to the ID
+			names = append(names, id)
+			break
+		}
+		names = append(names, node.Name)
+		id = node.ParentId
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, "/")
+}
+
+// ScopePath returns the path of a scope ID as accepted by ResolveScope
+func ScopePath(client *api.Client, id string) (string, error) {
+	tree, err := loadScopeTree(client)
+	if err != nil {
+		return "", err
+	}
+	return tree.path(id), nil
+}
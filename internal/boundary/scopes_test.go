@@ -0,0 +1,70 @@
+package boundary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScopeTreeResolve(t *testing.T) {
+	tree := scopeTree{
+		"o_org":     {Id: "o_org", Name: "org", ParentId: "global"},
+		"o_other":   {Id: "o_other", Name: "other", ParentId: "global"},
+		"p_orgdev":  {Id: "p_orgdev", Name: "dev", ParentId: "o_org"},
+		"p_orgprod": {Id: "p_orgprod", Name: "prod", ParentId: "o_org"},
+		"p_othdev":  {Id: "p_othdev", Name: "dev", ParentId: "o_other"},
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{name: "unique name", ref: "prod", want: "p_orgprod"},
+		{name: "org name", ref: "org", want: "o_org"},
+		{name: "path", ref: "org/dev", want: "p_orgdev"},
+		{name: "path with leading slash", ref: "/other/dev", want: "p_othdev"},
+		{name: "ambiguous name", ref: "dev", wantErr: "ambiguous"},
+		{name: "unknown name", ref: "stage", wantErr: "not found"},
+		{name: "unknown path", ref: "org/stage", wantErr: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tree.resolve(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolve(%q) error = %v, want error containing %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q) error = %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("resolve(%q) = %s, want %s", tt.ref, got, tt.want)
+			}
+		})
+	}
+
+	if got := tree.path("p_othdev"); got != "other/dev" {
+		t.Errorf("path(p_othdev) = %s, want other/dev", got)
+	}
+}
+
+func TestIsScopeId(t *testing.T) {
+	tests := map[string]bool{
+		"o_1234567890":  true,
+		"p_AbCdE12345":  true,
+		"p_payments":    false,
+		"o_org":         false,
+		"p_1234567890x": false,
+		"global":        false,
+		"dev":           false,
+	}
+	for ref, want := range tests {
+		if got := isScopeId(ref); got != want {
+			t.Errorf("isScopeId(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package boundary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pgboundary/internal/state"
+
+	"github.com/hashicorp/boundary/api"
+	"github.com/hashicorp/boundary/api/sessions"
+)
+
+// CancelSession cancels a session on its controller using a cached token. It
+// reports whether the controller had already terminated the session.
+func CancelSession(session state.Session) (bool, error) {
+	if session.SessionId == "" || session.Controller == "" {
+		return false, fmt.Errorf("no session ID recorded for target %s", session.Target)
+	}
+
+	token, err := findToken(session.Controller, session.TokenId)
+	if err != nil {
+		return false, err
+	}
+	if token == nil {
+		return false, fmt.Errorf("no valid token cached for %s, session %s stays open until it expires", session.Controller, session.SessionId)
+	}
+
+	client, err := newClient(session.Controller)
+	if err != nil {
+		return false, err
+	}
+	client.SetToken(token.Token)
+	sessionClient := sessions.NewClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := sessionClient.Read(ctx, session.SessionId)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to read session %s: %w", session.SessionId, err)
+	}
+
+	switch result.Item.Status {
+	case "canceling", "terminated":
+		return true, nil
+	}
+
+	if _, err := sessionClient.Cancel(ctx, session.SessionId, result.Item.Version); err != nil {
+		return false, fmt.Errorf("failed to cancel session %s: %w", session.SessionId, err)
+	}
+	return false, nil
+}
+
+// findToken returns the cached token with the given ID, or any other unexpired
+// token for the controller if it has been replaced since
+func findToken(controller, id string) (*Token, error) {
+	tokens, err := DefaultTokenStore().List()
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback *Token
+	for _, token := range tokens {
+		if token.Controller != normalizeAddr(controller) || token.Expired() {
+			continue
+		}
+		if token.Id == id {
+			return &token, nil
+		}
+		if fallback == nil {
+			fallback = &token
+		}
+	}
+	return fallback, nil
+}
@@ -0,0 +1,69 @@
+package boundary
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/hashicorp/boundary/api"
+	"github.com/hashicorp/boundary/api/targets"
+)
+
+// TargetInfo describes a Boundary target visible to the authenticated user
+type TargetInfo struct {
+	Id                string
+	Name              string
+	Type              string
+	ScopeId           string
+	ScopeName         string
+	ScopePath         string
+	AuthorizedActions []string
+}
+
+// CanConnect reports whether the user may start sessions to the target
+func (t TargetInfo) CanConnect() bool {
+	return slices.Contains(t.AuthorizedActions, "authorize-session")
+}
+
+// ListTargets recursively lists all targets below scope, sorted by scope path and name
+func ListTargets(client *api.Client, scope string) ([]TargetInfo, error) {
+	scopeId, err := ResolveScope(client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := targets.NewClient(client).List(ctx, scopeId, targets.WithRecursive(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list targets: %w", err)
+	}
+
+	infos := make([]TargetInfo, 0, len(result.Items))
+	for _, item := range result.Items {
+		info := TargetInfo{
+			Id:                item.Id,
+			Name:              item.Name,
+			Type:              item.Type,
+			ScopeId:           item.ScopeId,
+			AuthorizedActions: item.AuthorizedActions,
+		}
+		if item.Scope != nil {
+			info.ScopeName = item.Scope.Name
+		}
+		if info.ScopePath, err = ScopePath(client, item.ScopeId); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ScopePath != infos[j].ScopePath {
+			return infos[i].ScopePath < infos[j].ScopePath
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
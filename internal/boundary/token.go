@@ -0,0 +1,227 @@
+package boundary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/state"
+
+	"github.com/hashicorp/boundary/api"
+	"github.com/hashicorp/boundary/api/authtokens"
+)
+
+// expiryMargin treats tokens as expired slightly early so they do not run out mid-connect
+const expiryMargin = time.Minute
+
+// Token is a Boundary auth token cached between pgboundary invocations
+type Token struct {
+	Controller   string `json:"controller"`
+	ScopeId      string `json:"scope_id"`
+	AuthMethodId string `json:"auth_method_id"`
+	// LoginName is set for password and ldap logins, whose tokens belong to one user
+	LoginName      string    `json:"login_name,omitempty"`
+	Id             string    `json:"id"`
+	Token          string    `json:"token"`
+	ExpirationTime time.Time `json:"expiration_time"`
+}
+
+// Expired reports whether the token is (about to be) past its expiration time
+func (t Token) Expired() bool {
+	return time.Now().Add(expiryMargin).After(t.ExpirationTime)
+}
+
+func (t Token) matches(controller, scopeId, authMethodId, loginName string) bool {
+	return t.Controller == normalizeAddr(controller) && t.ScopeId == scopeId && t.AuthMethodId == authMethodId && t.LoginName == loginName
+}
+
+// TokenStore persists auth tokens keyed by controller address, scope ID, auth
+// method ID and login name
+type TokenStore struct {
+	Path string
+}
+
+// DefaultTokenStore returns the token store in the state directory
+func DefaultTokenStore() *TokenStore {
+	return &TokenStore{Path: filepath.Join(state.Dir, "tokens.json")}
+}
+
+// List returns all cached tokens, including expired ones
+func (s *TokenStore) List() ([]Token, error) {
+	content, err := os.ReadFile(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+
+	var tokens []Token
+	if err := json.Unmarshal(content, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token cache %s: %w", s.Path, err)
+	}
+	return tokens, nil
+}
+
+// Get returns the cached token for the given key, or nil if there is no unexpired one
+func (s *TokenStore) Get(controller, scopeId, authMethodId, loginName string) (*Token, error) {
+	tokens, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range tokens {
+		if token.matches(controller, scopeId, authMethodId, loginName) && !token.Expired() {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+// Put stores a token, replacing any previous token for the same key
+func (s *TokenStore) Put(token Token) error {
+	token.Controller = normalizeAddr(token.Controller)
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tokens, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	kept := []Token{token}
+	for _, t := range tokens {
+		if !t.matches(token.Controller, token.ScopeId, token.AuthMethodId, token.LoginName) && !t.Expired() {
+			kept = append(kept, t)
+		}
+	}
+	return s.save(kept)
+}
+
+// Remove deletes the token for the given key
+func (s *TokenStore) Remove(controller, scopeId, authMethodId, loginName string) error {
+	_, err := s.purge(func(t Token) bool { return t.matches(controller, scopeId, authMethodId, loginName) })
+	return err
+}
+
+// Purge deletes all tokens for the given controller, or all tokens if controller is empty,
+// and returns the removed tokens
+func (s *TokenStore) Purge(controller string) ([]Token, error) {
+	return s.purge(func(t Token) bool {
+		return controller == "" || t.Controller == normalizeAddr(controller)
+	})
+}
+
+func (s *TokenStore) purge(match func(Token) bool) ([]Token, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tokens, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var kept, removed []Token
+	for _, t := range tokens {
+		if match(t) {
+			removed = append(removed, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, s.save(kept)
+}
+
+// lock serializes read-modify-write operations on the cache, both between the
+// parallel logins of one connect and between pgboundary processes. flock locks
+// belong to the open file, so goroutines exclude each other as well.
+func (s *TokenStore) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+
+	lock, err := fileutil.Lock(s.Path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock token cache: %w", err)
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to release token cache lock: %v\n", err)
+		}
+	}, nil
+}
+
+func (s *TokenStore) save(tokens []Token) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token cache: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(s.Path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	return nil
+}
+
+// validateToken checks with the controller whether a cached token is still accepted
+func validateToken(ctx context.Context, client *api.Client, token *Token) (bool, error) {
+	tokenClient := client.Clone()
+	tokenClient.SetToken(token.Token)
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := authtokens.NewClient(tokenClient).Read(ctx, token.Id)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, api.ErrUnauthorized), errors.Is(err, api.ErrPermissionDenied), errors.Is(err, api.ErrNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to validate cached token: %w", err)
+	}
+}
+
+// RevokeToken deletes a token on its controller
+func RevokeToken(token Token) error {
+	client, err := newClient(token.Controller)
+	if err != nil {
+		return err
+	}
+	client.SetToken(token.Token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if _, err := authtokens.NewClient(client).Delete(ctx, token.Id); err != nil {
+		if errors.Is(err, api.ErrUnauthorized) || errors.Is(err, api.ErrNotFound) {
+			// Already expired or revoked
+			return nil
+		}
+		return fmt.Errorf("failed to revoke token %s: %w", token.Id, err)
+	}
+	return nil
+}
+
+func normalizeAddr(addr string) string {
+	return strings.TrimRight(addr, "/")
+}
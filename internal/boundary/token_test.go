@@ -0,0 +1,128 @@
+package boundary
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenStore(t *testing.T) {
+	store := &TokenStore{Path: filepath.Join(t.TempDir(), "tokens.json")}
+
+	valid := Token{
+		Controller:     "https://boundary.example.com/",
+		ScopeId:        "o_1234567890",
+		AuthMethodId:   "amoidc_1234567890",
+		Id:             "at_1",
+		Token:          "at_1_secret",
+		ExpirationTime: time.Now().Add(time.Hour),
+	}
+	expired := Token{
+		Controller:     "https://boundary.stage.example.com",
+		ScopeId:        "global",
+		AuthMethodId:   "amoidc_0987654321",
+		Id:             "at_2",
+		Token:          "at_2_secret",
+		ExpirationTime: time.Now().Add(-time.Hour),
+	}
+
+	if got, err := store.Get(valid.Controller, valid.ScopeId, valid.AuthMethodId, ""); err != nil || got != nil {
+		t.Fatalf("Get() on empty store = %v, %v", got, err)
+	}
+
+	for _, token := range []Token{valid, expired} {
+		if err := store.Put(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.Get("https://boundary.example.com", valid.ScopeId, valid.AuthMethodId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Token != valid.Token {
+		t.Errorf("Get() = %+v, want token %s", got, valid.Token)
+	}
+
+	if got, _ := store.Get(expired.Controller, expired.ScopeId, expired.AuthMethodId, ""); got != nil {
+		t.Errorf("Get() returned expired token %+v", got)
+	}
+
+	// Password and ldap tokens belong to the user who logged in
+	alice, bob := valid, valid
+	alice.AuthMethodId, alice.LoginName, alice.Id, alice.Token = "ampw_1234567890", "alice", "at_3", "at_3_secret"
+	bob.AuthMethodId, bob.LoginName, bob.Id, bob.Token = "ampw_1234567890", "bob", "at_4", "at_4_secret"
+	for _, token := range []Token{alice, bob} {
+		if err := store.Put(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []Token{alice, bob} {
+		if got, _ := store.Get(want.Controller, want.ScopeId, want.AuthMethodId, want.LoginName); got == nil || got.Token != want.Token {
+			t.Errorf("Get(%s) = %+v, want token %s", want.LoginName, got, want.Token)
+		}
+	}
+	if got, _ := store.Get(alice.Controller, alice.ScopeId, alice.AuthMethodId, "carol"); got != nil {
+		t.Errorf("Get(carol) returned the token of %s", got.LoginName)
+	}
+
+	// Replacing a token keeps a single entry per key
+	renewed := valid
+	renewed.Token = "at_1_renewed"
+	if err := store.Put(renewed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get(valid.Controller, valid.ScopeId, valid.AuthMethodId, ""); got == nil || got.Token != renewed.Token {
+		t.Errorf("Get() after renewal = %+v, want token %s", got, renewed.Token)
+	}
+
+	removed, err := store.Purge("https://boundary.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("Purge() removed %+v, want the tokens of %s", removed, valid.Controller)
+	}
+
+	tokens, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Id == valid.Id {
+			t.Errorf("token %s still cached after purge", token.Id)
+		}
+	}
+}
+
+func TestTokenStoreConcurrentPut(t *testing.T) {
+	store := &TokenStore{Path: filepath.Join(t.TempDir(), "tokens.json")}
+
+	// Parallel connects cache tokens for different controllers at the same time
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			token := Token{
+				Controller:     fmt.Sprintf("https://boundary-%d.example.com", i),
+				ScopeId:        "global",
+				AuthMethodId:   "ampw_1234567890",
+				Id:             fmt.Sprintf("at_%d", i),
+				ExpirationTime: time.Now().Add(time.Hour),
+			}
+			if err := store.Put(token); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	tokens, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 20 {
+		t.Errorf("got %d cached tokens, want 20", len(tokens))
+	}
+}
@@ -0,0 +1,222 @@
+package pgbouncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pgboundary/config"
+	"pgboundary/internal/process"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// adminTimeout bounds connecting to and querying the admin console
+	adminTimeout = 5 * time.Second
+	// pauseTimeout bounds waiting for PAUSE, which blocks until the server
+	// connections of the database are released
+	pauseTimeout = 30 * time.Second
+)
+
+// AdminConsole is a connection to the pgbouncer admin console, the virtual
+// "pgbouncer" database
+type AdminConsole struct {
+	conn *pgx.Conn
+}
+
+// ConnectAdmin connects to the admin console as the first admin or stats user of
+// the pgbouncer config. The password is taken from PGBOUNDARY_ADMIN_PASSWORD or,
+// if stored in plain text, from the auth_file.
+func ConnectAdmin(cfg *config.Config) (*AdminConsole, error) {
+	user, err := adminUser(cfg)
+	if err != nil {
+		return nil, err
+	}
+	host, err := listenHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	connConfig, err := pgx.ParseConfig("sslmode=disable")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin console config: %w", err)
+	}
+	connConfig.Host = host
+	connConfig.Port = uint16(cfg.PgBouncer.ListenPort)
+	connConfig.Database = "pgbouncer"
+	connConfig.User = user
+	connConfig.Password = adminPassword(cfg, user)
+	connConfig.Fallbacks = nil
+	connConfig.RuntimeParams = map[string]string{"application_name": "pgboundary"}
+	connConfig.ConnectTimeout = adminTimeout
+	// The admin console only supports the simple query protocol
+	connConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+
+	if process.Verbose {
+		fmt.Fprintf(os.Stderr, "connecting to pgbouncer admin console at %s:%d as %s\n", host, cfg.PgBouncer.ListenPort, user)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pgbouncer admin console: %w", err)
+	}
+	return &AdminConsole{conn: conn}, nil
+}
+
+// Close closes the admin console connection
+func (a *AdminConsole) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+	return a.conn.Close(ctx)
+}
+
+// show runs a SHOW command and returns its rows keyed by column name. Columns
+// differ between pgbouncer versions, so callers look up the ones they know.
+func (a *AdminConsole) show(what string) ([]map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, "SHOW "+what)
+	if err != nil {
+		return nil, fmt.Errorf("SHOW %s failed: %w", what, err)
+	}
+	defer rows.Close()
+
+	var result []map[string]string
+	fields := rows.FieldDescriptions()
+	for rows.Next() {
+		row := make(map[string]string, len(fields))
+		for i, value := range rows.RawValues() {
+			row[fields[i].Name] = string(value)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SHOW %s failed: %w", what, err)
+	}
+	return result, nil
+}
+
+// Reload makes pgbouncer re-read its config; an invalid config is reported as error
+func (a *AdminConsole) Reload() error {
+	return a.command("RELOAD", adminTimeout)
+}
+
+// Pause waits for the server connections of a database to be released and
+// holds new queries until Resume
+func (a *AdminConsole) Pause(database string) error {
+	return a.command("PAUSE "+quoteIdent(database), pauseTimeout)
+}
+
+// Resume continues a paused or killed database
+func (a *AdminConsole) Resume(database string) error {
+	return a.command("RESUME "+quoteIdent(database), adminTimeout)
+}
+
+// Kill drops all client and server connections of a database
+func (a *AdminConsole) Kill(database string) error {
+	return a.command("KILL "+quoteIdent(database), adminTimeout)
+}
+
+// Shutdown stops pgbouncer
+func (a *AdminConsole) Shutdown() error {
+	err := a.command("SHUTDOWN", adminTimeout)
+	var pgErr *pgconn.PgError
+	if err != nil && !errors.As(err, &pgErr) {
+		// pgbouncer closes the connection without replying when it shuts down
+		return nil
+	}
+	return err
+}
+
+func (a *AdminConsole) command(command string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if process.Verbose {
+		fmt.Fprintf(os.Stderr, "sending %s to pgbouncer admin console\n", command)
+	}
+	if _, err := a.conn.Exec(ctx, command); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			return fmt.Errorf("pgbouncer rejected %s: %s: %w", command, pgErr.Message, err)
+		}
+		return fmt.Errorf("%s failed: %w", command, err)
+	}
+	return nil
+}
+
+// quoteIdent quotes a database name for the admin console, which otherwise only
+// accepts lower case letters, digits and underscores
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// closeAdmin closes an admin console connection, only reporting failures in verbose mode
+func closeAdmin(console *AdminConsole) {
+	if err := console.Close(); err != nil && process.Verbose {
+		fmt.Fprintf(os.Stderr, "failed to close admin console connection: %v\n", err)
+	}
+}
+
+func adminUser(cfg *config.Config) (string, error) {
+	if len(cfg.PgBouncer.AdminUsers) > 0 {
+		return cfg.PgBouncer.AdminUsers[0], nil
+	}
+	if len(cfg.PgBouncer.StatsUsers) > 0 {
+		return cfg.PgBouncer.StatsUsers[0], nil
+	}
+	return "", errors.New("no admin_users or stats_users configured in pgbouncer config")
+}
+
+// listenHost returns the address to reach pgbouncer at, preferring TCP
+// over the unix socket
+func listenHost(cfg *config.Config) (string, error) {
+	if cfg.PgBouncer.ListenAddr != "" {
+		host := strings.TrimSpace(strings.Split(cfg.PgBouncer.ListenAddr, ",")[0])
+		switch host {
+		case "*", "0.0.0.0":
+			return "127.0.0.1", nil
+		case "::":
+			return "::1", nil
+		}
+		return host, nil
+	}
+	if cfg.PgBouncer.UnixSocketDir != "" {
+		return cfg.PgBouncer.UnixSocketDir, nil
+	}
+	return "", errors.New("pgbouncer is configured without listen_addr and unix_socket_dir")
+}
+
+func adminPassword(cfg *config.Config, user string) string {
+	if password := os.Getenv(AdminPasswordEnv); password != "" {
+		return password
+	}
+	if cfg.PgBouncer.AuthFile == "" {
+		return ""
+	}
+
+	users, err := readAuthFile(cfg.PgBouncer.AuthFile)
+	if err != nil {
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "failed to read admin password: %v\n", err)
+		}
+		return ""
+	}
+	password := users[user]
+	if !isPlaintextPassword(password) {
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "password of %s in auth file is hashed, set %s to connect to the admin console\n", user, AdminPasswordEnv)
+		}
+		return ""
+	}
+	return password
+}
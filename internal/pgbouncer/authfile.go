@@ -0,0 +1,231 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"pgboundary/internal/fileutil"
+)
+
+// AdminPasswordEnv holds the admin console password when the auth_file only
+// contains hashed passwords
+const AdminPasswordEnv = "PGBOUNDARY_ADMIN_PASSWORD"
+
+// Password kinds of auth_file entries
+const (
+	PasswordScram     = "scram-sha-256"
+	PasswordMD5       = "md5"
+	PasswordPlaintext = "plaintext"
+)
+
+// AuthUser is an entry of the pgbouncer auth_file
+type AuthUser struct {
+	Name string
+	// Secret is a SCRAM verifier, an MD5 hash or a plaintext password
+	Secret string
+}
+
+// Kind returns how the password of the user is stored
+func (u AuthUser) Kind() string {
+	switch {
+	case strings.HasPrefix(u.Secret, "SCRAM-SHA-256$"):
+		return PasswordScram
+	case !isPlaintextPassword(u.Secret):
+		return PasswordMD5
+	default:
+		return PasswordPlaintext
+	}
+}
+
+// ReadAuthUsers returns the entries of a pgbouncer auth_file in file order
+func ReadAuthUsers(path string) ([]AuthUser, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth file: %w", err)
+	}
+
+	var users []AuthUser
+	for _, line := range strings.Split(string(content), "\n") {
+		if user, password, ok := parseAuthLine(line); ok {
+			users = append(users, AuthUser{Name: user, Secret: password})
+		}
+	}
+	return users, nil
+}
+
+// readAuthFile returns the users of a pgbouncer auth_file with their password or password hash
+func readAuthFile(path string) (map[string]string, error) {
+	entries, err := ReadAuthUsers(path)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]string, len(entries))
+	for _, user := range entries {
+		users[user.Name] = user.Secret
+	}
+	return users, nil
+}
+
+// PutAuthUser adds a user to the auth_file or replaces its secret, keeping all
+// other lines. It reports whether the user already existed.
+func PutAuthUser(path string, user AuthUser) (bool, error) {
+	unlock, err := lockState()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	lines, err := readAuthLines(path)
+	if err != nil {
+		return false, err
+	}
+
+	replaced := false
+	for i, line := range lines {
+		if name, _, ok := parseAuthLine(line); ok && name == user.Name {
+			lines[i] = formatAuthLine(user)
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, formatAuthLine(user))
+	}
+	return replaced, writeAuthLines(path, lines)
+}
+
+// RemoveAuthUser removes a user from the auth_file and reports whether it existed
+func RemoveAuthUser(path, name string) (bool, error) {
+	unlock, err := lockState()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	lines, err := readAuthLines(path)
+	if err != nil {
+		return false, err
+	}
+
+	kept := lines[:0]
+	removed := false
+	for _, line := range lines {
+		if user, _, ok := parseAuthLine(line); ok && user == name {
+			removed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !removed {
+		return false, nil
+	}
+	return true, writeAuthLines(path, kept)
+}
+
+// AuthFileWarnings reports plaintext passwords and permissions allowing other
+// users to read the auth_file
+func AuthFileWarnings(path string) []string {
+	var warnings []string
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		warnings = append(warnings, fmt.Sprintf("auth file %s is accessible by other users (mode %s), run `chmod 600 %s`", path, info.Mode().Perm(), path))
+	}
+
+	users, err := ReadAuthUsers(path)
+	if err != nil {
+		return warnings
+	}
+	for _, user := range users {
+		if user.Kind() == PasswordPlaintext {
+			warnings = append(warnings, fmt.Sprintf("auth file %s stores the password of %q in plain text, run `pgboundary users add %s` to hash it", path, user.Name, user.Name))
+		}
+	}
+	return warnings
+}
+
+// authType returns the pgbouncer auth_type needed for the secrets in the
+// auth_file, or "" if the template's setting can be kept
+func authType(users []AuthUser) string {
+	hasScram := false
+	for _, user := range users {
+		switch user.Kind() {
+		case PasswordMD5:
+			// md5 falls back to SCRAM for users with a SCRAM verifier
+			return "md5"
+		case PasswordScram:
+			hasScram = true
+		}
+	}
+	if hasScram {
+		return "scram-sha-256"
+	}
+	return ""
+}
+
+func readAuthLines(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read auth file: %w", err)
+	}
+	trimmed := strings.TrimRight(string(content), "\n")
+	if trimmed == "" {
+		return nil, nil
+	}
+	return strings.Split(trimmed, "\n"), nil
+}
+
+func writeAuthLines(path string, lines []string) error {
+	content := strings.Join(lines, "\n") + "\n"
+	if err := fileutil.WriteFileAtomic(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write auth file: %w", err)
+	}
+	return nil
+}
+
+func formatAuthLine(user AuthUser) string {
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+	return quote(user.Name) + " " + quote(user.Secret)
+}
+
+// parseAuthLine parses a `"user" "password"` line, where a double quote inside
+// a value is escaped by doubling it
+func parseAuthLine(line string) (string, string, bool) {
+	var fields []string
+	rest := strings.TrimSpace(line)
+	for len(fields) < 2 {
+		if !strings.HasPrefix(rest, `"`) {
+			return "", "", false
+		}
+		var value strings.Builder
+		i := 1
+		for ; i < len(rest); i++ {
+			if rest[i] == '"' {
+				if i+1 < len(rest) && rest[i+1] == '"' {
+					value.WriteByte('"')
+					i++
+					continue
+				}
+				break
+			}
+			value.WriteByte(rest[i])
+		}
+		if i >= len(rest) {
+			return "", "", false
+		}
+		fields = append(fields, value.String())
+		rest = strings.TrimSpace(rest[i+1:])
+	}
+	return fields[0], fields[1], true
+}
+
+// isPlaintextPassword reports whether an auth_file password is stored in plain text
+// rather than as MD5 or SCRAM hash
+func isPlaintextPassword(password string) bool {
+	if strings.HasPrefix(password, "SCRAM-SHA-256$") {
+		return false
+	}
+	return !(len(password) == 35 && strings.HasPrefix(password, "md5"))
+}
@@ -0,0 +1,109 @@
+package pgbouncer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseAuthLine(t *testing.T) {
+	tests := []struct {
+		line         string
+		wantUser     string
+		wantPassword string
+		wantOk       bool
+	}{
+		{line: `"foo" "bar"`, wantUser: "foo", wantPassword: "bar", wantOk: true},
+		{line: `  "admin"   "se""cret"  `, wantUser: "admin", wantPassword: `se"cret`, wantOk: true},
+		{line: `"scram" "SCRAM-SHA-256$4096:c2FsdA==$a2V5:c2VydmVy" ""`, wantUser: "scram", wantPassword: "SCRAM-SHA-256$4096:c2FsdA==$a2V5:c2VydmVy", wantOk: true},
+		{line: `;"foo" "bar"`},
+		{line: `"foo"`},
+		{line: `"foo" "bar`},
+		{line: ``},
+	}
+
+	for _, tt := range tests {
+		user, password, ok := parseAuthLine(tt.line)
+		if ok != tt.wantOk || user != tt.wantUser || password != tt.wantPassword {
+			t.Errorf("parseAuthLine(%q) = %q, %q, %v, want %q, %q, %v", tt.line, user, password, ok, tt.wantUser, tt.wantPassword, tt.wantOk)
+		}
+	}
+}
+
+func TestIsPlaintextPassword(t *testing.T) {
+	tests := map[string]bool{
+		"bar":                                 true,
+		"md5-is-not-a-hash":                   true,
+		"md5d41d8cd98f00b204e9800998ecf8427e": false,
+		"SCRAM-SHA-256$4096:c2FsdA==$a2V5:c2VydmVy": false,
+	}
+	for password, want := range tests {
+		if got := isPlaintextPassword(password); got != want {
+			t.Errorf("isPlaintextPassword(%q) = %v, want %v", password, got, want)
+		}
+	}
+}
+
+func TestScramVerifier(t *testing.T) {
+	// Computed independently with PBKDF2-HMAC-SHA256 as specified in RFC 5802
+	want := "SCRAM-SHA-256$4096:MDEyMzQ1Njc4OWFiY2RlZg==$nddkk2g0NbAgIB53NunbUsJiVX+Rd/MmZAB0OBhUAuk=:4y3NZhhRoX3/TnqIua9P320mLWLJIQwihBlZP67wgqg="
+	got, err := scramVerifier("bar", []byte("0123456789abcdef"), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("scramVerifier() = %q, want %q", got, want)
+	}
+}
+
+func TestPutAndRemoveAuthUser(t *testing.T) {
+	path := filepath.Join(useTempState(t), "pg_auth")
+	if err := os.WriteFile(path, []byte(";\"username\" \"password\"\n\"foo\" \"bar\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	scram, err := ScramVerifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced, err := PutAuthUser(path, AuthUser{Name: "foo", Secret: scram}); err != nil || !replaced {
+		t.Fatalf("PutAuthUser(foo) = %v, %v, want true, nil", replaced, err)
+	}
+	if replaced, err := PutAuthUser(path, AuthUser{Name: "alice", Secret: scram}); err != nil || replaced {
+		t.Fatalf("PutAuthUser(alice) = %v, %v, want false, nil", replaced, err)
+	}
+	if removed, err := RemoveAuthUser(path, "nobody"); err != nil || removed {
+		t.Fatalf("RemoveAuthUser(nobody) = %v, %v, want false, nil", removed, err)
+	}
+
+	users, err := ReadAuthUsers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+		if user.Kind() != PasswordScram {
+			t.Errorf("user %s has %s password, want %s", user.Name, user.Kind(), PasswordScram)
+		}
+	}
+	if !slices.Equal(names, []string{"foo", "alice"}) {
+		t.Errorf("users = %v, want [foo alice]", names)
+	}
+	if got := authType(users); got != "scram-sha-256" {
+		t.Errorf("authType() = %q, want scram-sha-256", got)
+	}
+
+	if removed, err := RemoveAuthUser(path, "foo"); err != nil || !removed {
+		t.Fatalf("RemoveAuthUser(foo) = %v, %v, want true, nil", removed, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ";\"username\" \"password\"\n\"alice\" \"" + scram + "\"\n"
+	if string(content) != want {
+		t.Errorf("auth file = %q, want %q", content, want)
+	}
+}
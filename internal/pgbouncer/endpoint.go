@@ -0,0 +1,135 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pgboundary/config"
+)
+
+// ClientPasswordEnv holds the password of the local pgbouncer user when the
+// auth_file only contains its hash
+const ClientPasswordEnv = "PGBOUNDARY_PASSWORD"
+
+// Endpoint is where clients reach a target through pgbouncer
+type Endpoint struct {
+	// Host is an address or, for unix sockets, a directory
+	Host     string
+	Port     int
+	Database string
+	User     string
+	// Password is empty if it is only stored hashed and not set in PGBOUNDARY_PASSWORD
+	Password string
+}
+
+// ClientEndpoint resolves the pgbouncer endpoint of a target. Without a user, the
+// first auth_file user that is not an admin or stats user is taken.
+func ClientEndpoint(cfg *config.Config, targetName, user string) (*Endpoint, error) {
+	if _, ok := cfg.Targets[targetName]; !ok {
+		return nil, fmt.Errorf("target %q not found in configuration file", targetName)
+	}
+	host, err := listenHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []AuthUser
+	if cfg.PgBouncer.AuthFile != "" {
+		if users, err = ReadAuthUsers(cfg.PgBouncer.AuthFile); err != nil {
+			return nil, err
+		}
+	}
+	if user == "" {
+		user = clientUser(cfg, users)
+	}
+	if user == "" {
+		return nil, fmt.Errorf("no user found in auth file, add one with `pgboundary users add`")
+	}
+
+	endpoint := &Endpoint{Host: host, Port: cfg.PgBouncer.ListenPort, Database: targetName, User: user}
+	if password := os.Getenv(ClientPasswordEnv); password != "" {
+		endpoint.Password = password
+	} else {
+		for _, u := range users {
+			if u.Name == user && u.Kind() == PasswordPlaintext {
+				endpoint.Password = u.Secret
+			}
+		}
+	}
+	return endpoint, nil
+}
+
+func clientUser(cfg *config.Config, users []AuthUser) string {
+	for _, u := range users {
+		if !slices.Contains(cfg.PgBouncer.AdminUsers, u.Name) && !slices.Contains(cfg.PgBouncer.StatsUsers, u.Name) {
+			return u.Name
+		}
+	}
+	if len(users) > 0 {
+		return users[0].Name
+	}
+	return ""
+}
+
+// IsUnixSocket reports whether the endpoint is a unix socket directory
+func (e Endpoint) IsUnixSocket() bool {
+	return strings.HasPrefix(e.Host, "/")
+}
+
+// Variables returns the libpq environment variables and DATABASE_URL for the
+// endpoint as ordered name/value pairs
+func (e Endpoint) Variables() [][2]string {
+	vars := [][2]string{
+		{"PGHOST", e.Host},
+		{"PGPORT", strconv.Itoa(e.Port)},
+		{"PGDATABASE", e.Database},
+		{"PGUSER", e.User},
+	}
+	if e.Password != "" {
+		vars = append(vars, [2]string{"PGPASSWORD", e.Password})
+	}
+	return append(vars, [2]string{"DATABASE_URL", e.URL()})
+}
+
+// Environment returns Variables in the NAME=value form of os.Environ
+func (e Endpoint) Environment() []string {
+	var env []string
+	for _, v := range e.Variables() {
+		env = append(env, v[0]+"="+v[1])
+	}
+	return env
+}
+
+// URL returns the libpq connection URI of the endpoint
+func (e Endpoint) URL() string {
+	u := url.URL{Scheme: "postgresql", Path: "/" + e.Database}
+	if e.Password != "" {
+		u.User = url.UserPassword(e.User, e.Password)
+	} else {
+		u.User = url.User(e.User)
+	}
+	if e.IsUnixSocket() {
+		u.RawQuery = url.Values{"host": {e.Host}, "port": {strconv.Itoa(e.Port)}}.Encode()
+	} else {
+		u.Host = net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	}
+	return u.String()
+}
+
+// JDBCURL returns the URL for the PostgreSQL JDBC driver, which cannot connect
+// to unix sockets
+func (e Endpoint) JDBCURL() (string, error) {
+	if e.IsUnixSocket() {
+		return "", fmt.Errorf("JDBC cannot connect to the unix socket in %s, set listen_addr in the pgbouncer config", e.Host)
+	}
+	query := url.Values{"user": {e.User}}
+	if e.Password != "" {
+		query.Set("password", e.Password)
+	}
+	return fmt.Sprintf("jdbc:postgresql://%s/%s?%s", net.JoinHostPort(e.Host, strconv.Itoa(e.Port)), url.PathEscape(e.Database), query.Encode()), nil
+}
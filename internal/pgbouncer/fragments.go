@@ -0,0 +1,144 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"pgboundary/config"
+	"pgboundary/internal/boundary"
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/state"
+)
+
+// legacyFragmentPattern matches the temp directories older pgboundary versions
+// wrote fragments to
+const legacyFragmentPattern = "pgwrap-*"
+
+// isLegacyInclude reports whether line is an include of a fragment written into
+// the user's config file by older pgboundary versions
+func isLegacyInclude(line string) bool {
+	path, ok := strings.CutPrefix(strings.TrimSpace(line), "%include")
+	if !ok {
+		return false
+	}
+	path = strings.TrimSpace(path)
+	matched, _ := filepath.Match(legacyFragmentPattern, filepath.Base(filepath.Dir(path)))
+	return matched && filepath.Ext(path) == ".ini"
+}
+
+// writeFragment stores the database entry of a target, which contains the
+// session credentials, in the private runtime directory
+func writeFragment(targetName, content string) (string, error) {
+	if err := fileutil.EnsurePrivateDir(state.RuntimeDir); err != nil {
+		return "", fmt.Errorf("failed to prepare runtime directory: %w", err)
+	}
+
+	path := fragmentPath(targetName)
+	if err := fileutil.WriteFileAtomic(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write config fragment: %w", err)
+	}
+	return path, nil
+}
+
+func fragmentPath(targetName string) string {
+	name := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(targetName)
+	return filepath.Join(state.RuntimeDir, name+".ini")
+}
+
+// GCResult describes what a garbage collection pass cleaned up
+type GCResult struct {
+	// Unregistered are targets whose boundary process is no longer running
+	Unregistered []string
+	// Removed are fragment files and legacy temp directories not used by any session
+	Removed []string
+	// Warnings are credential files that other users can read
+	Warnings []string
+}
+
+// GarbageCollect unregisters sessions whose boundary process is gone and
+// deletes fragments that no registered session refers to
+func GarbageCollect(cfg *config.Config) (*GCResult, error) {
+	unlock, err := lockState()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	result := &GCResult{}
+
+	sessions, err := state.Default().List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection details: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, session := range sessions {
+		if session.BoundaryPid > 0 && !boundary.OwnsProcess(session) {
+			if err := removeConnection(cfg, session.Target); err != nil {
+				return nil, fmt.Errorf("failed to remove connection %q: %w", session.Target, err)
+			}
+			result.Unregistered = append(result.Unregistered, session.Target)
+			continue
+		}
+		referenced[session.IncludeFile] = true
+	}
+
+	// Fragments in the runtime directory
+	fragments, err := filepath.Glob(filepath.Join(state.RuntimeDir, "*.ini"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config fragments: %w", err)
+	}
+	for _, fragment := range fragments {
+		if referenced[fragment] {
+			result.Warnings = append(result.Warnings, credentialFileWarnings(fragment)...)
+			continue
+		}
+		if err := os.Remove(fragment); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove config fragment: %w", err)
+		}
+		result.Removed = append(result.Removed, fragment)
+	}
+	if info, err := os.Stat(state.RuntimeDir); err == nil && info.Mode().Perm()&0077 != 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("runtime directory %s is accessible by other users (mode %s)", state.RuntimeDir, info.Mode().Perm()))
+	}
+
+	// Temp directories of older versions, which were never cleaned up
+	legacyDirs, err := filepath.Glob(filepath.Join(os.TempDir(), legacyFragmentPattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legacy config fragments: %w", err)
+	}
+	for _, dir := range legacyDirs {
+		fragment := filepath.Join(dir, "db.ini")
+		if referenced[fragment] || !ownedByUser(dir) {
+			result.Warnings = append(result.Warnings, credentialFileWarnings(fragment)...)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to remove legacy config fragment: %w", err)
+		}
+		result.Removed = append(result.Removed, dir)
+	}
+
+	return result, nil
+}
+
+// credentialFileWarnings warns about a file containing credentials that other users can read
+func credentialFileWarnings(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0004 == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s contains credentials and is world-readable (mode %s)", path, info.Mode().Perm())}
+}
+
+func ownedByUser(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,67 @@
+package pgbouncer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"pgboundary/config"
+	"pgboundary/internal/state"
+)
+
+func TestGarbageCollect(t *testing.T) {
+	tmpDir := useTempState(t)
+	t.Setenv("TMPDIR", tmpDir)
+
+	confFile := filepath.Join(tmpDir, "pg_config.ini")
+	if err := os.WriteFile(confFile, []byte("[pgbouncer]\npidfile = pgbouncer.pid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{PgBouncer: config.PgBouncerConfig{WorkDir: tmpDir, ConfFile: confFile}}
+
+	active, err := writeFragment("active", "[databases]\nactive = host=127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := writeFragment("stale", "[databases]\nstale = host=127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyDir := filepath.Join(tmpDir, "pgwrap-123")
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyDir, "db.ini"), []byte("[databases]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Default().Put(state.Session{Target: "active", IncludeFile: active}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(state.RuntimeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("runtime directory mode = %v, want 0700", info.Mode().Perm())
+	}
+
+	result, err := GarbageCollect(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(result.Removed, []string{stale, legacyDir}) {
+		t.Errorf("removed = %v, want %v", result.Removed, []string{stale, legacyDir})
+	}
+	if len(result.Unregistered) != 0 || len(result.Warnings) != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("active fragment was removed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale fragment still exists: %v", err)
+	}
+}
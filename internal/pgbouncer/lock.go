@@ -0,0 +1,29 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/state"
+)
+
+// lockState serializes read-modify-write operations on the session registry, the
+// generated config, the config fragments and the auth_file across concurrent
+// pgboundary processes. The returned function releases the lock.
+func lockState() (func(), error) {
+	if err := os.MkdirAll(state.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lock, err := fileutil.Lock(filepath.Join(state.Dir, "state.lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock pgboundary state: %w", err)
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to release state lock: %v\n", err)
+		}
+	}, nil
+}
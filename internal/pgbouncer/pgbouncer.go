@@ -5,67 +5,213 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"pgboundary/config"
 	"pgboundary/internal/boundary"
 	"pgboundary/internal/process"
+	"pgboundary/internal/state"
+)
 
-	"gopkg.in/ini.v1"
+// pgbouncer is controlled through these, so tests can replace it with a fake
+var (
+	reloadPgBouncer   = reload
+	shutdownPgBouncer = shutdown
 )
 
+// shutdownTimeout bounds waiting for pgbouncer to exit after asking it to shut down
+const shutdownTimeout = 5 * time.Second
+
+// UpdateConfig registers the boundary session of a target that is not connected
+// yet and adds its database entry to the generated config
 func UpdateConfig(cfg *config.Config, targetName string, conn *boundary.Connection) error {
 	if cfg == nil || conn == nil {
 		return fmt.Errorf("invalid configuration or connection")
 	}
 
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another pgboundary process may have connected the target in the meantime
+	existing, err := state.Default().Get(targetName)
+	if err != nil {
+		return fmt.Errorf("failed to get connection details: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("target %q is already connected", targetName)
+	}
+
+	return addConnection(cfg, targetName, conn)
+}
+
+// addConnection registers a session and renders it into the generated config.
+// The caller holds the state lock.
+func addConnection(cfg *config.Config, targetName string, conn *boundary.Connection) error {
 	target, ok := cfg.Targets[targetName]
 	if !ok {
 		return fmt.Errorf("target %q not found in configuration", targetName)
 	}
 
-	tmpDir, err := os.MkdirTemp("", "pgwrap-*")
+	configContent := formatDatabaseConfig(targetName, conn, target)
+	fragment, err := writeFragment(targetName, configContent)
 	if err != nil {
-		return fmt.Errorf("failed to create temp dir: %w", err)
+		return err
 	}
 
-	tmpFile := filepath.Join(tmpDir, "db.ini")
+	session := conn.Session(targetName)
+	session.IncludeFile = fragment
+	session.ConnectedAt = time.Now()
+	if err := state.Default().Put(session); err != nil {
+		return fmt.Errorf("failed to register session: %w", err)
+	}
+
+	if err := writeConfig(cfg); err != nil {
+		return fmt.Errorf("failed to update pgbouncer config: %w", err)
+	}
+
+	return nil
+}
 
-	// Extract config string creation for better readability
-	configContent := formatDatabaseConfig(targetName, conn, target.Database)
+// ReplaceConnection rewrites the database entry of an already configured target
+// to point at a renewed boundary session
+func ReplaceConnection(cfg *config.Config, targetName string, conn *boundary.Connection) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	if err := os.WriteFile(tmpFile, []byte(configContent), 0600); err != nil {
-		return fmt.Errorf("failed to write temp config: %w", err)
+	if err := removeConnection(cfg, targetName); err != nil {
+		return fmt.Errorf("failed to remove previous connection: %w", err)
 	}
+	return addConnection(cfg, targetName, conn)
+}
 
-	f, err := os.OpenFile(cfg.PgBouncer.ConfFile, os.O_APPEND|os.O_WRONLY, 0644)
+// SwapConnection points the database entry of a connected target at a renewed
+// boundary session without dropping its client connections: the database is
+// paused, its entry rewritten and reloaded, and then resumed, so only the
+// server connections are recycled. Without the admin console the entry is
+// replaced with a plain reload.
+func SwapConnection(cfg *config.Config, targetName string, conn *boundary.Connection) error {
+	console, err := ConnectAdmin(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to open pgbouncer config: %w", err)
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "admin console unavailable, replacing connection without pause: %v\n", err)
+		}
+		return replaceAndReload(cfg, targetName, conn)
 	}
+	defer closeAdmin(console)
+
+	if err := console.Pause(targetName); err != nil {
+		// PAUSE may time out on busy session pools, which also closes the console connection
+		fmt.Fprintf(os.Stderr, "Warning: failed to pause %q, client connections may be dropped: %v\n", targetName, err)
+		if err := replaceAndReload(cfg, targetName, conn); err != nil {
+			return err
+		}
+		resumeDatabase(cfg, targetName)
+		return nil
+	}
+
+	// Never leave the database paused, whatever happens to the swap
 	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Printf("failed to close pgbouncer config: %v\n", err)
+		if err := console.Resume(targetName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to resume %q: %v\n", targetName, err)
 		}
 	}()
 
-	if _, err := fmt.Fprintf(f, "\n%%include %s\n", tmpFile); err != nil {
-		return fmt.Errorf("failed to update pgbouncer config: %w", err)
+	if err := ReplaceConnection(cfg, targetName, conn); err != nil {
+		return err
+	}
+	if err := console.Reload(); err != nil {
+		return fmt.Errorf("failed to reload pgbouncer: %w", err)
 	}
-
 	return nil
 }
 
-func formatDatabaseConfig(targetName string, conn *boundary.Connection, dbName string) string {
-	return fmt.Sprintf(
-		"; boundary_pid=%d\n[databases]\n%s = host=%s port=%s dbname=%s user=%s password=%s",
-		conn.Pid, targetName, conn.Host, conn.Port, dbName, conn.Username, conn.Password,
-	)
+func replaceAndReload(cfg *config.Config, targetName string, conn *boundary.Connection) error {
+	if err := ReplaceConnection(cfg, targetName, conn); err != nil {
+		return err
+	}
+	return Reload(cfg)
 }
 
+// resumeDatabase resumes a database over a new admin console connection, in case
+// a failed PAUSE left it paused
+func resumeDatabase(cfg *config.Config, database string) {
+	console, err := ConnectAdmin(cfg)
+	if err != nil {
+		return
+	}
+	defer closeAdmin(console)
+
+	if err := console.Resume(database); err != nil && process.Verbose {
+		fmt.Fprintf(os.Stderr, "failed to resume %q: %v\n", database, err)
+	}
+}
+
+func formatDatabaseConfig(targetName string, conn *boundary.Connection, target config.Target) string {
+	settings := [][2]string{
+		{"host", conn.Host},
+		{"port", conn.Port},
+		{"dbname", target.Database},
+		{"user", conn.Username},
+		{"password", conn.Password},
+	}
+	settings = append(settings, target.Pool.Settings()...)
+
+	parts := make([]string, 0, len(settings))
+	for _, setting := range settings {
+		parts = append(parts, setting[0]+"="+quoteConnValue(setting[1]))
+	}
+	return fmt.Sprintf("[databases]\n%s = %s", targetName, strings.Join(parts, " "))
+}
+
+// quoteConnValue quotes a value of a pgbouncer database entry if needed, e.g.
+// `datestyle='ISO, MDY'`
+func quoteConnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t,'") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Reload makes pgbouncer re-read the generated config, starting it if it is not running.
+// The admin console is used if reachable, so a rejected config is reported;
+// otherwise pgbouncer is signaled.
 func Reload(cfg *config.Config) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return reloadPgBouncer(cfg)
+}
+
+// reload reloads or starts pgbouncer. The caller holds the state lock.
+func reload(cfg *config.Config) error {
+	console, err := ConnectAdmin(cfg)
+	if err != nil {
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "admin console unavailable, falling back to signals: %v\n", err)
+		}
+		return reloadWithSignal(cfg)
+	}
+	defer closeAdmin(console)
+
+	if err := console.Reload(); err != nil {
+		return fmt.Errorf("failed to reload pgbouncer: %w", err)
+	}
+	return nil
+}
+
+func reloadWithSignal(cfg *config.Config) error {
 	pidBytes, err := os.ReadFile(cfg.PgBouncer.PidFile)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -82,7 +228,7 @@ func Reload(cfg *config.Config) error {
 	}
 
 	if process.Verbose {
-		fmt.Printf("sending HUP signal to pgbouncer with PID %d\n", pid)
+		fmt.Fprintf(os.Stderr, "sending HUP signal to pgbouncer with PID %d\n", pid)
 	}
 	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
 		if errors.Is(err, syscall.ESRCH) {
@@ -95,7 +241,46 @@ func Reload(cfg *config.Config) error {
 	return nil
 }
 
+// Shutdown stops pgbouncer through the admin console, or with SIGTERM if the
+// console is unreachable, and waits for it to exit
 func Shutdown(cfg *config.Config) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return shutdownPgBouncer(cfg)
+}
+
+// shutdown stops pgbouncer. The caller holds the state lock, so a concurrent
+// connect cannot reload the exiting process.
+func shutdown(cfg *config.Config) error {
+	console, err := ConnectAdmin(cfg)
+	if err != nil {
+		if process.Verbose {
+			fmt.Fprintf(os.Stderr, "admin console unavailable, falling back to signals: %v\n", err)
+		}
+		err = shutdownWithSignal(cfg)
+	} else {
+		err = console.Shutdown()
+		closeAdmin(console)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to shut down pgbouncer: %w", err)
+	}
+
+	deadline := time.Now().Add(shutdownTimeout)
+	for time.Now().Before(deadline) {
+		if running, _, _ := CheckStatus(cfg.PgBouncer.PidFile); !running {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("pgbouncer did not exit within %s", shutdownTimeout)
+}
+
+func shutdownWithSignal(cfg *config.Config) error {
 	pidBytes, err := os.ReadFile(cfg.PgBouncer.PidFile)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -120,34 +305,46 @@ func Shutdown(cfg *config.Config) error {
 	return nil
 }
 
-func CleanConfig(cfg *config.Config) error {
-	content, err := os.ReadFile(cfg.PgBouncer.ConfFile)
+// ClearSessions unregisters all sessions, renders a pgbouncer config without them
+// and returns the removed sessions. Reading and clearing the registry in one
+// locked step ensures that no concurrently connected session is dropped
+// without being returned.
+func ClearSessions(cfg *config.Config) ([]state.Session, error) {
+	unlock, err := lockState()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
+	defer unlock()
 
-	lines := strings.Split(string(content), "\n")
-	newLines := make([]string, 0, len(lines))
+	return cleanConfig(cfg)
+}
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, ";") || !strings.HasPrefix(trimmed, "%include") {
-			newLines = append(newLines, line)
-		}
+// cleanConfig is ClearSessions for callers holding the state lock
+func cleanConfig(cfg *config.Config) ([]state.Session, error) {
+	sessions, err := state.Default().Clear()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear session registry: %w", err)
+	}
+	for _, session := range sessions {
+		removeIncludeFile(session.IncludeFile)
 	}
 
-	// Ensure file ends with a newline
-	newContent := strings.TrimSpace(strings.Join(newLines, "\n")) + "\n"
-	if err := os.WriteFile(cfg.PgBouncer.ConfFile, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	if hasLegacyIncludes(cfg) {
+		fmt.Fprintf(os.Stderr, "Note: %s contains %%include lines from an older pgboundary version; they are ignored and can be removed\n", cfg.PgBouncer.ConfFile)
 	}
 
-	return nil
+	return sessions, writeConfig(cfg)
 }
 
 // Helper function to start pgbouncer
 func startPgBouncer(cfg *config.Config) error {
-	cmd := exec.Command("pgbouncer", "--daemon", cfg.PgBouncer.ConfFile)
+	if _, err := os.Stat(GeneratedConfigPath()); os.IsNotExist(err) {
+		if err := writeConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command("pgbouncer", "--daemon", GeneratedConfigPath())
 	cmd.Dir = cfg.PgBouncer.WorkDir
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to start pgbouncer: %w", err)
@@ -174,220 +371,123 @@ func CheckStatus(pidFile string) (bool, int, error) {
 	return true, pid, nil
 }
 
-type ConnectionDetail struct {
-	Name        string
-	BoundaryPid int
-}
-
-func GetConnectionDetails(configFile string) ([]ConnectionDetail, error) {
-	var connections []ConnectionDetail
-
-	// First read the file to process includes
-	content, err := os.ReadFile(configFile)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config file %s: %w", configFile, err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "%include") {
-			includePath := strings.TrimSpace(strings.TrimPrefix(trimmed, "%include"))
-			included, err := parseIncludedFile(includePath)
-			if err != nil {
-				fmt.Printf("Warning: error processing include file %s: %v\n", includePath, err)
-				continue
-			}
-			connections = append(connections, included...)
-		}
-	}
-
-	return connections, nil
-}
-
-func parseIncludedFile(filePath string) ([]ConnectionDetail, error) {
-	var connections []ConnectionDetail
-
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error reading include file %s: %w", filePath, err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	var boundaryPid int
-
-	// Parse the boundary PID from comments if present
-	for _, line := range lines {
-		if strings.HasPrefix(line, "; boundary_pid=") {
-			pidStr := strings.TrimPrefix(line, "; boundary_pid=")
-			if pid, err := strconv.Atoi(strings.TrimSpace(pidStr)); err == nil {
-				boundaryPid = pid
-				break
-			}
-		}
-	}
-
-	// Parse the file as INI
-	file, err := ini.LoadSources(ini.LoadOptions{
-		IgnoreInlineComment: true,
-	}, filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing config file %s: %w", filePath, err)
-	}
-
-	// Get databases section
-	dbSection := file.Section("databases")
-	if dbSection != nil {
-		for _, key := range dbSection.Keys() {
-			connections = append(connections, ConnectionDetail{
-				Name:        key.Name(),
-				BoundaryPid: boundaryPid,
-			})
-		}
-	}
-
-	return connections, nil
-}
-
+// ShutdownConnection ends the session of a single target and removes it from pgbouncer.
+// Pgbouncer itself is shut down once no sessions remain.
 func ShutdownConnection(cfg *config.Config, connectionName string) error {
-	// Get connection details to find the boundary PID
-	connections, err := GetConnectionDetails(cfg.PgBouncer.ConfFile)
+	session, err := unregisterConnection(cfg, connectionName)
 	if err != nil {
-		return fmt.Errorf("failed to get connection details: %w", err)
-	}
-
-	var targetConn *ConnectionDetail
-	for _, conn := range connections {
-		if conn.Name == connectionName {
-			targetConn = &conn
-			break
-		}
+		return err
 	}
 
-	if targetConn == nil {
-		return fmt.Errorf("connection %q not found", connectionName)
+	// End the boundary session; pgbouncer is updated regardless. This talks to
+	// the controller, so it runs without holding the state lock.
+	if err := boundary.Shutdown([]state.Session{*session}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
 
-	// Kill the boundary process if it exists
-	if targetConn.BoundaryPid > 0 && process.IsProcessType(targetConn.BoundaryPid, "boundary") {
-		if err := process.KillProcess(targetConn.BoundaryPid); err != nil {
-			return fmt.Errorf("failed to kill boundary process: %w", err)
-		}
-	}
-
-	// Remove the connection from pgbouncer config
-	if err := removeConnection(cfg, connectionName); err != nil {
-		return fmt.Errorf("failed to remove connection from config: %w", err)
+	unlock, err := lockState()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	// Check if there are any remaining boundary connections
-	remainingConnections, err := GetConnectionDetails(cfg.PgBouncer.ConfFile)
+	remaining, err := state.Default().List()
 	if err != nil {
 		return fmt.Errorf("failed to check remaining connections: %w", err)
 	}
 
-	hasActiveBoundary := false
-	for _, conn := range remainingConnections {
-		if conn.BoundaryPid > 0 {
-			hasActiveBoundary = true
-			break
-		}
-	}
-
 	// If no more boundary connections, shutdown pgbouncer
-	if !hasActiveBoundary {
+	if len(remaining) == 0 {
 		if process.Verbose {
-			fmt.Println("no more boundary connections, shutting down pgbouncer")
+			fmt.Fprintln(os.Stderr, "no more boundary connections, shutting down pgbouncer")
 		}
-		if err := Shutdown(cfg); err != nil {
+		if err := shutdownPgBouncer(cfg); err != nil {
 			return fmt.Errorf("failed to shutdown pgbouncer: %w", err)
 		}
-		if err := CleanConfig(cfg); err != nil {
+		if _, err := cleanConfig(cfg); err != nil {
 			return fmt.Errorf("failed to clean pgbouncer config: %w", err)
 		}
 		return nil
 	}
 
 	// Otherwise just reload pgbouncer
-	if err := Reload(cfg); err != nil {
+	if err := reloadPgBouncer(cfg); err != nil {
 		return fmt.Errorf("failed to reload pgbouncer: %w", err)
 	}
 
 	return nil
 }
 
-func removeConnection(cfg *config.Config, connectionName string) error {
-	content, err := os.ReadFile(cfg.PgBouncer.ConfFile)
+// unregisterConnection removes a connected target from the generated config and
+// returns its session
+func unregisterConnection(cfg *config.Config, connectionName string) (*state.Session, error) {
+	unlock, err := lockState()
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	var newLines []string
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Check included files for the connection
-		if strings.HasPrefix(trimmed, "%include") {
-			includePath := strings.TrimSpace(strings.TrimPrefix(trimmed, "%include"))
-			if hasConnection(includePath, connectionName) {
-				// Remove the included file
-				func() {
-					if err := os.Remove(includePath); err != nil {
-						fmt.Printf("failed to remove include file %s: %v\n", includePath, err)
-					}
-				}()
-				continue
-			}
-		}
-
-		newLines = append(newLines, line)
+		return nil, err
 	}
+	defer unlock()
 
-	// Write the updated config back
-	newContent := strings.Join(newLines, "\n")
-	if err := os.WriteFile(cfg.PgBouncer.ConfFile, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	session, err := state.Default().Get(connectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection details: %w", err)
+	}
+	if session == nil {
+		return nil, fmt.Errorf("connection %q not found", connectionName)
 	}
 
-	return nil
+	// Remove the connection from pgbouncer config first, so a watching
+	// supervisor does not renew the session we are about to end
+	if err := removeConnection(cfg, connectionName); err != nil {
+		return nil, fmt.Errorf("failed to remove connection from config: %w", err)
+	}
+	return session, nil
 }
 
-func hasConnection(filePath string, connectionName string) bool {
-	_, err := os.ReadFile(filePath)
+// RemoveConnection unregisters the session of a target and drops it from the
+// generated config, without ending the session or reloading pgbouncer
+func RemoveConnection(cfg *config.Config, connectionName string) error {
+	unlock, err := lockState()
 	if err != nil {
-		return false
+		return err
 	}
+	defer unlock()
 
-	// Parse as INI
-	file, err := ini.LoadSources(ini.LoadOptions{
-		IgnoreInlineComment: true,
-	}, filePath)
+	return removeConnection(cfg, connectionName)
+}
+
+// removeConnection unregisters the session of a target and drops it from the
+// generated config. The caller holds the state lock.
+func removeConnection(cfg *config.Config, connectionName string) error {
+	session, err := state.Default().Remove(connectionName)
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to unregister session: %w", err)
+	}
+	if session == nil {
+		return nil
 	}
 
-	// Check databases section
-	dbSection := file.Section("databases")
-	if dbSection == nil {
-		return false
+	if err := writeConfig(cfg); err != nil {
+		return err
 	}
 
-	return dbSection.HasKey(connectionName)
+	removeIncludeFile(session.IncludeFile)
+	return nil
+}
+
+func removeIncludeFile(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "failed to remove include file %s: %v\n", path, err)
+	}
 }
 
 func IsTargetConnected(cfg *config.Config, target string) (bool, error) {
-	connections, err := GetConnectionDetails(cfg.PgBouncer.ConfFile)
+	session, err := state.Default().Get(target)
 	if err != nil {
 		return false, fmt.Errorf("failed to get connection details: %w", err)
 	}
-
-	for _, conn := range connections {
-		if conn.Name == target {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return session != nil, nil
 }
@@ -0,0 +1,215 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"pgboundary/config"
+	"pgboundary/internal/boundary"
+	"pgboundary/internal/state"
+)
+
+// useTempState points the state and runtime directories at a temp directory
+func useTempState(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	stateDir, runtimeDir := state.Dir, state.RuntimeDir
+	state.Dir = filepath.Join(tmpDir, "state")
+	state.RuntimeDir = filepath.Join(tmpDir, "run")
+	t.Cleanup(func() {
+		state.Dir, state.RuntimeDir = stateDir, runtimeDir
+	})
+	return tmpDir
+}
+
+func TestFormatDatabaseConfig(t *testing.T) {
+	conn := &boundary.Connection{Host: "127.0.0.1", Port: "41234", Username: "u_ro", Password: "s3cr3t"}
+
+	tests := []struct {
+		name   string
+		target config.Target
+		want   string
+	}{
+		{
+			name:   "defaults",
+			target: config.Target{Database: "app"},
+			want:   "[databases]\nreporting = host=127.0.0.1 port=41234 dbname=app user=u_ro password=s3cr3t",
+		},
+		{
+			name: "pool settings",
+			target: config.Target{
+				Database: "app",
+				Pool:     config.PoolConfig{PoolMode: "transaction", PoolSize: "5", DateStyle: "ISO, MDY", TimeZone: "UTC"},
+			},
+			want: "[databases]\nreporting = host=127.0.0.1 port=41234 dbname=app user=u_ro password=s3cr3t " +
+				"pool_mode=transaction pool_size=5 datestyle='ISO, MDY' timezone=UTC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatDatabaseConfig("reporting", conn, tt.target); got != tt.want {
+				t.Errorf("formatDatabaseConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentConnectShutdown(t *testing.T) {
+	tmpDir := useTempState(t)
+
+	confFile := filepath.Join(tmpDir, "pg_config.ini")
+	if err := os.WriteFile(confFile, []byte("[pgbouncer]\npidfile = pgbouncer.pid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		PgBouncer: config.PgBouncerConfig{WorkDir: tmpDir, ConfFile: confFile, PidFile: filepath.Join(tmpDir, "pgbouncer.pid")},
+		Targets:   make(map[string]config.Target),
+	}
+	const targets, iterations = 8, 15
+	for i := range targets {
+		cfg.Targets[fmt.Sprintf("db%d", i)] = config.Target{Database: fmt.Sprintf("db%d", i)}
+	}
+
+	// The fake pgbouncer checks that the generated config it would load matches the registry
+	var reloads, shutdowns atomic.Int32
+	checkConfig := func(*config.Config) error {
+		content, err := os.ReadFile(GeneratedConfigPath())
+		if err != nil {
+			return err
+		}
+		var includes []string
+		for _, line := range strings.Split(string(content), "\n") {
+			if path, ok := strings.CutPrefix(line, "%include "); ok {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("generated config includes missing fragment: %v", err)
+				}
+				includes = append(includes, path)
+			}
+		}
+		sessions, err := state.Default().List()
+		if err != nil {
+			return err
+		}
+		var registered []string
+		for _, session := range sessions {
+			registered = append(registered, session.IncludeFile)
+		}
+		slices.Sort(includes)
+		slices.Sort(registered)
+		if !slices.Equal(includes, registered) {
+			t.Errorf("generated config includes %v, registry has %v", includes, registered)
+		}
+		return nil
+	}
+	reloadPgBouncer = func(cfg *config.Config) error {
+		reloads.Add(1)
+		return checkConfig(cfg)
+	}
+	shutdownPgBouncer = func(cfg *config.Config) error {
+		shutdowns.Add(1)
+		return checkConfig(cfg)
+	}
+	t.Cleanup(func() {
+		reloadPgBouncer, shutdownPgBouncer = reload, shutdown
+	})
+
+	var wg sync.WaitGroup
+	for i := range targets {
+		target := fmt.Sprintf("db%d", i)
+		wg.Go(func() {
+			for j := range iterations {
+				conn := &boundary.Connection{Host: "127.0.0.1", Port: fmt.Sprint(40000 + i*100 + j), Username: "u", Password: "p"}
+				if err := UpdateConfig(cfg, target, conn); err != nil {
+					t.Errorf("UpdateConfig(%s): %v", target, err)
+					return
+				}
+				if err := Reload(cfg); err != nil {
+					t.Errorf("Reload: %v", err)
+					return
+				}
+				if connected, err := IsTargetConnected(cfg, target); err != nil || !connected {
+					t.Errorf("target %s not connected after UpdateConfig: %v", target, err)
+					return
+				}
+				if err := ShutdownConnection(cfg, target); err != nil {
+					t.Errorf("ShutdownConnection(%s): %v", target, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	sessions, err := state.Default().List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("sessions left after shutdown: %v", sessions)
+	}
+	fragments, _ := filepath.Glob(filepath.Join(state.RuntimeDir, "*.ini"))
+	if len(fragments) != 0 {
+		t.Errorf("fragments left after shutdown: %v", fragments)
+	}
+	if reloads.Load() == 0 || shutdowns.Load() == 0 {
+		t.Errorf("reloads = %d, shutdowns = %d, want both > 0", reloads.Load(), shutdowns.Load())
+	}
+}
+
+func TestClearSessions(t *testing.T) {
+	tmpDir := useTempState(t)
+
+	confFile := filepath.Join(tmpDir, "pg_config.ini")
+	if err := os.WriteFile(confFile, []byte("[pgbouncer]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		PgBouncer: config.PgBouncerConfig{WorkDir: tmpDir, ConfFile: confFile},
+		Targets:   make(map[string]config.Target),
+	}
+	const targets = 20
+	for i := range targets {
+		cfg.Targets[fmt.Sprintf("db%d", i)] = config.Target{}
+	}
+
+	// Every session connected while clearing is either returned or still registered
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Go(func() {
+			conn := &boundary.Connection{Host: "127.0.0.1", Port: fmt.Sprint(40000 + i)}
+			if err := UpdateConfig(cfg, fmt.Sprintf("db%d", i), conn); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	var cleared []state.Session
+	wg.Go(func() {
+		var err error
+		if cleared, err = ClearSessions(cfg); err != nil {
+			t.Error(err)
+		}
+	})
+	wg.Wait()
+
+	remaining, err := state.Default().List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, session := range append(cleared, remaining...) {
+		if seen[session.Target] {
+			t.Errorf("target %s both cleared and registered", session.Target)
+		}
+		seen[session.Target] = true
+	}
+	if len(seen) != targets {
+		t.Errorf("cleared %d and kept %d sessions, want %d in total", len(cleared), len(remaining), targets)
+	}
+}
@@ -0,0 +1,227 @@
+package pgbouncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"pgboundary/config"
+	"pgboundary/internal/fileutil"
+	"pgboundary/internal/state"
+)
+
+// pathSettings are [pgbouncer] settings holding file paths, which are resolved
+// against the workdir since the generated config lives elsewhere
+var pathSettings = map[string]bool{
+	"auth_file":            true,
+	"auth_hba_file":        true,
+	"auth_ident_file":      true,
+	"logfile":              true,
+	"pidfile":              true,
+	"unix_socket_dir":      true,
+	"client_tls_key_file":  true,
+	"client_tls_cert_file": true,
+	"client_tls_ca_file":   true,
+	"server_tls_key_file":  true,
+	"server_tls_cert_file": true,
+	"server_tls_ca_file":   true,
+}
+
+// GeneratedConfigPath is the pgbouncer config rendered by pgboundary, which
+// pgbouncer is started with
+func GeneratedConfigPath() string {
+	return filepath.Join(state.Dir, "pgbouncer.ini")
+}
+
+// RenderConfig writes the generated pgbouncer config: the settings of the user's
+// config file, which is treated as a read-only template, plus a [databases]
+// section with the template's databases and one include per registered session
+func RenderConfig(cfg *config.Config) error {
+	unlock, err := lockState()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeConfig(cfg)
+}
+
+// writeConfig renders the generated config. The caller holds the state lock.
+func writeConfig(cfg *config.Config) error {
+	template, err := os.ReadFile(cfg.PgBouncer.ConfFile)
+	if err != nil {
+		return fmt.Errorf("failed to read pgbouncer config: %w", err)
+	}
+
+	sessions, err := state.Default().List()
+	if err != nil {
+		return fmt.Errorf("failed to get connection details: %w", err)
+	}
+
+	var includes []string
+	for _, session := range sessions {
+		if session.IncludeFile != "" {
+			includes = append(includes, "%include "+session.IncludeFile)
+		}
+	}
+
+	settings := make(map[string]string)
+	if cfg.PgBouncer.AuthFile != "" {
+		if users, err := ReadAuthUsers(cfg.PgBouncer.AuthFile); err == nil {
+			needed := authType(users)
+			override, kept := overrideAuthType(string(template), needed)
+			if override != "" {
+				settings["auth_type"] = override
+			} else if kept != "" {
+				fmt.Fprintf(os.Stderr, "Warning: keeping auth_type = %s of %s, the hashed passwords in the auth_file need %s\n", kept, cfg.PgBouncer.ConfFile, needed)
+			}
+		}
+	}
+
+	content := renderConfig(string(template), cfg.PgBouncer.ConfFile, cfg.PgBouncer.WorkDir, settings, includes)
+	if err := os.MkdirAll(state.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(GeneratedConfigPath(), []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write generated pgbouncer config: %w", err)
+	}
+	return nil
+}
+
+// overrideAuthType returns the auth_type to set for the secrets in the auth_file,
+// which only replaces plain, md5 or no setting in the template. Other methods are
+// the user's choice, they are returned as kept if the auth_file needs another one.
+func overrideAuthType(template, needed string) (override, kept string) {
+	if needed == "" {
+		return "", ""
+	}
+	switch current := templateSetting(template, "auth_type"); current {
+	case "", "plain", "md5":
+		return needed, ""
+	case needed:
+		return "", ""
+	default:
+		return "", current
+	}
+}
+
+// templateSetting returns the value of a [pgbouncer] setting in the template
+func templateSetting(template, key string) string {
+	section := ""
+	value := ""
+	for _, line := range strings.Split(template, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.TrimSpace(strings.Trim(trimmed, "[]"))
+			continue
+		}
+		if section != "pgbouncer" || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		if k, v, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(k) == key {
+			// The last occurrence wins, as in pgbouncer
+			value = strings.TrimSpace(v)
+		}
+	}
+	return value
+}
+
+// renderConfig builds the generated config from the template. settings replace
+// or extend the template's [pgbouncer] section, includes are appended to [databases].
+func renderConfig(template, templatePath, workDir string, settings map[string]string, includes []string) string {
+	out := []string{fmt.Sprintf("; generated by pgboundary from %s, do not edit", templatePath)}
+
+	section := ""
+	hasDatabases := false
+	applied := make(map[string]bool)
+	// Settings missing from the template go at the end of [pgbouncer]
+	appendSettings := func() {
+		keys := make([]string, 0, len(settings))
+		for key := range settings {
+			if !applied[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		lines := make([]string, 0, len(keys))
+		for _, key := range keys {
+			lines = append(lines, key+" = "+settings[key])
+		}
+		// Keep the blank lines separating the sections
+		end := len(out)
+		for end > 0 && strings.TrimSpace(out[end-1]) == "" {
+			end--
+		}
+		out = slices.Insert(out, end, lines...)
+	}
+
+	for _, line := range strings.Split(template, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Includes written into the template by older pgboundary versions, the
+		// user's own includes are kept
+		if isLegacyInclude(line) {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			// Managed databases go at the end of the template's [databases] section
+			switch section {
+			case "databases":
+				out = append(out, includes...)
+			case "pgbouncer":
+				appendSettings()
+			}
+			section = strings.TrimSpace(strings.Trim(trimmed, "[]"))
+			if section == "databases" {
+				hasDatabases = true
+			}
+			out = append(out, trimmed)
+			continue
+		}
+
+		if section == "pgbouncer" && trimmed != "" && !strings.HasPrefix(trimmed, ";") {
+			if key, value, ok := strings.Cut(trimmed, "="); ok {
+				key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+				if override, ok := settings[key]; ok {
+					line = key + " = " + override
+					applied[key] = true
+				} else if pathSettings[key] && value != "" && !filepath.IsAbs(value) {
+					line = key + " = " + filepath.Join(workDir, value)
+				}
+			}
+		}
+		out = append(out, line)
+	}
+
+	switch section {
+	case "databases":
+		out = append(out, includes...)
+	case "pgbouncer":
+		appendSettings()
+	}
+	if !hasDatabases {
+		out = append(out, "", "[databases]")
+		out = append(out, includes...)
+	}
+
+	return strings.TrimRight(strings.Join(out, "\n"), "\n") + "\n"
+}
+
+// hasLegacyIncludes reports whether the user's config file still contains
+// includes written by older pgboundary versions
+func hasLegacyIncludes(cfg *config.Config) bool {
+	content, err := os.ReadFile(cfg.PgBouncer.ConfFile)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if isLegacyInclude(line) {
+			return true
+		}
+	}
+	return false
+}
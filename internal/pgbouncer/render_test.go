@@ -0,0 +1,117 @@
+package pgbouncer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderConfig(t *testing.T) {
+	template := `[pgbouncer]
+pool_mode = session
+listen_port = 5432
+auth_file = pg_auth
+logfile = /var/log/pgbouncer.log
+pidfile = pgbouncer.pid
+; auth_hba_file = hba.conf
+
+[databases]
+static = host=db.example.com dbname=static
+
+%include /tmp/pgwrap-123/db.ini
+%include /etc/pgbouncer/extra.ini
+`
+	includes := []string{"%include /run/user/1000/pgboundary/demo-dev.ini"}
+	got := renderConfig(template, "/home/jane/.pgboundary/pg_config.ini", "/home/jane/.pgboundary", nil, includes)
+
+	for _, want := range []string{
+		"; generated by pgboundary from /home/jane/.pgboundary/pg_config.ini, do not edit\n",
+		"auth_file = /home/jane/.pgboundary/pg_auth\n",
+		"logfile = /var/log/pgbouncer.log\n",
+		"pidfile = /home/jane/.pgboundary/pgbouncer.pid\n",
+		"; auth_hba_file = hba.conf\n",
+		"static = host=db.example.com dbname=static\n",
+		"%include /etc/pgbouncer/extra.ini\n",
+		"%include /run/user/1000/pgboundary/demo-dev.ini\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered config is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "pgwrap-123") {
+		t.Errorf("rendered config contains legacy include:\n%s", got)
+	}
+	if strings.Count(got, "[databases]") != 1 {
+		t.Errorf("rendered config should have exactly one [databases] section:\n%s", got)
+	}
+}
+
+func TestRenderConfigWithoutDatabases(t *testing.T) {
+	template := "[pgbouncer]\npidfile = pgbouncer.pid\n\n[users]\nfoo = pool_mode=transaction\n"
+	includes := []string{"%include /tmp/a.ini", "%include /tmp/b.ini"}
+	got := renderConfig(template, "pg_config.ini", "/work", nil, includes)
+
+	if !strings.HasSuffix(got, "[databases]\n%include /tmp/a.ini\n%include /tmp/b.ini\n") {
+		t.Errorf("managed [databases] section missing at the end:\n%s", got)
+	}
+	if !strings.Contains(got, "[users]\nfoo = pool_mode=transaction\n") {
+		t.Errorf("template sections not preserved:\n%s", got)
+	}
+}
+
+func TestRenderConfigSettings(t *testing.T) {
+	template := "[pgbouncer]\nauth_type = plain\nlisten_port = 5432\n\n[databases]\n"
+	settings := map[string]string{"auth_type": "scram-sha-256", "max_client_conn": "50"}
+	got := renderConfig(template, "pg_config.ini", "/work", settings, nil)
+
+	want := "; generated by pgboundary from pg_config.ini, do not edit\n" +
+		"[pgbouncer]\nauth_type = scram-sha-256\nlisten_port = 5432\nmax_client_conn = 50\n\n[databases]\n"
+	if got != want {
+		t.Errorf("renderConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestIsLegacyInclude(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"%include /tmp/pgwrap-123/db.ini", true},
+		{"  %include /var/folders/xy/T/pgwrap-4567/db.ini", true},
+		{"%include /etc/pgbouncer/extra.ini", false},
+		{"%include /tmp/pgwrap-123/notes.txt", false},
+		{"%include /tmp/db.ini", false},
+		{"; %include /tmp/pgwrap-123/db.ini", false},
+		{"pidfile = /tmp/pgwrap-123/db.ini", false},
+	}
+	for _, tt := range tests {
+		if got := isLegacyInclude(tt.line); got != tt.want {
+			t.Errorf("isLegacyInclude(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestOverrideAuthType(t *testing.T) {
+	tests := []struct {
+		template     string
+		needed       string
+		wantOverride string
+		wantKept     string
+	}{
+		{"[pgbouncer]\nlisten_port = 6432\n", "scram-sha-256", "scram-sha-256", ""},
+		{"[pgbouncer]\nauth_type = plain\n", "scram-sha-256", "scram-sha-256", ""},
+		{"[pgbouncer]\nauth_type = md5\n", "scram-sha-256", "scram-sha-256", ""},
+		{"[pgbouncer]\nauth_type = scram-sha-256\n", "scram-sha-256", "", ""},
+		{"[pgbouncer]\nauth_type = hba\nauth_hba_file = hba.conf\n", "scram-sha-256", "", "hba"},
+		{"[pgbouncer]\nauth_type = cert\n", "md5", "", "cert"},
+		{"[pgbouncer]\nauth_type = trust\n", "scram-sha-256", "", "trust"},
+		{"[pgbouncer]\n; auth_type = pam\n", "scram-sha-256", "scram-sha-256", ""},
+		{"[pgbouncer]\nauth_type = pam\n", "", "", ""},
+		{"[databases]\nauth_type = host=db\n", "md5", "md5", ""},
+	}
+	for _, tt := range tests {
+		override, kept := overrideAuthType(tt.template, tt.needed)
+		if override != tt.wantOverride || kept != tt.wantKept {
+			t.Errorf("overrideAuthType(%q, %q) = %q, %q, want %q, %q", tt.template, tt.needed, override, kept, tt.wantOverride, tt.wantKept)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pgbouncer
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DatabaseStats combines SHOW POOLS, SHOW STATS and SHOW CLIENTS for one pgbouncer database
+type DatabaseStats struct {
+	Database       string
+	ClientsActive  int
+	ClientsWaiting int
+	ServersActive  int
+	ServersIdle    int
+	TotalQueries   int64
+	AvgQueryTime   time.Duration
+	// Applications are the distinct application names of connected clients
+	Applications []string
+}
+
+// DatabaseStats returns the statistics of all pgbouncer databases keyed by name
+func (a *AdminConsole) DatabaseStats() (map[string]*DatabaseStats, error) {
+	stats := make(map[string]*DatabaseStats)
+	get := func(database string) *DatabaseStats {
+		if stats[database] == nil {
+			stats[database] = &DatabaseStats{Database: database}
+		}
+		return stats[database]
+	}
+
+	pools, err := a.show("POOLS")
+	if err != nil {
+		return nil, err
+	}
+	// One pool per database and user
+	for _, row := range pools {
+		s := get(row["database"])
+		s.ClientsActive += atoi(row["cl_active"])
+		s.ClientsWaiting += atoi(row["cl_waiting"])
+		s.ServersActive += atoi(row["sv_active"])
+		s.ServersIdle += atoi(row["sv_idle"]) + atoi(row["sv_used"]) + atoi(row["sv_tested"]) + atoi(row["sv_login"])
+	}
+
+	rows, err := a.show("STATS")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		s := get(row["database"])
+		// Column names before pgbouncer 1.8
+		s.TotalQueries = atoi64(firstOf(row, "total_query_count", "total_requests"))
+		s.AvgQueryTime = time.Duration(atoi64(firstOf(row, "avg_query_time", "avg_query"))) * time.Microsecond
+	}
+
+	clients, err := a.show("CLIENTS")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range clients {
+		s := get(row["database"])
+		if app := row["application_name"]; app != "" && !slices.Contains(s.Applications, app) {
+			s.Applications = append(s.Applications, app)
+		}
+	}
+
+	return stats, nil
+}
+
+func firstOf(row map[string]string, columns ...string) string {
+	for _, column := range columns {
+		if value, ok := row[column]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func atoi64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
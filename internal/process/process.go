@@ -3,6 +3,7 @@ package process
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"syscall"
 
@@ -41,7 +42,7 @@ func IsProcessType(pid int, processName string) bool {
 
 	matches := strings.EqualFold(name, processName) || strings.HasPrefix(cmdline, processName)
 	if Verbose && matches {
-		fmt.Printf("found %s process: %d\n", processName, pid)
+		fmt.Fprintf(os.Stderr, "found %s process: %d\n", processName, pid)
 	}
 	return matches
 }
@@ -50,3 +51,32 @@ func IsProcessType(pid int, processName string) bool {
 func Processes() ([]*process.Process, error) {
 	return process.Processes()
 }
+
+// CreateTime returns the creation time of a process in milliseconds since the epoch
+func CreateTime(pid int) (int64, error) {
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return 0, fmt.Errorf("process %d not found: %w", pid, err)
+	}
+
+	createTime, err := proc.CreateTime()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get creation time of process %d: %w", pid, err)
+	}
+	return createTime, nil
+}
+
+// IsSameProcess checks if the process with the given PID is still the one that was
+// created at createTime (ms since the epoch), which guards against PID reuse
+func IsSameProcess(pid int, createTime int64) bool {
+	current, err := CreateTime(pid)
+	if err != nil {
+		return false
+	}
+
+	matches := current == createTime
+	if Verbose && !matches {
+		fmt.Fprintf(os.Stderr, "process %d was restarted or reused, skipping\n", pid)
+	}
+	return matches
+}
@@ -0,0 +1,170 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"pgboundary/internal/fileutil"
+
+	"github.com/adrg/xdg"
+)
+
+// Dir holds pgboundary's persistent state, e.g. the session registry and token cache
+var Dir = filepath.Join(xdg.StateHome, "pgboundary")
+
+// RuntimeDir holds the pgbouncer config fragments with session credentials. It
+// lives in the per-user runtime directory, which is cleared on logout or reboot,
+// or in a per-user directory below the system temp directory if there is none.
+var RuntimeDir = runtimeDir()
+
+func runtimeDir() string {
+	if info, err := os.Stat(xdg.RuntimeDir); err == nil && info.IsDir() {
+		return filepath.Join(xdg.RuntimeDir, "pgboundary")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pgboundary-%d", os.Getuid()))
+}
+
+const registryVersion = 1
+
+// Session is a boundary session started by pgboundary for a target
+type Session struct {
+	Target string `json:"target"`
+	// BoundaryPid and BoundaryStartTime (ms since epoch) identify the
+	// `boundary connect` process, guarding against PID reuse
+	BoundaryPid       int       `json:"boundary_pid"`
+	BoundaryStartTime int64     `json:"boundary_start_time"`
+	ProxyHost         string    `json:"proxy_host"`
+	ProxyPort         string    `json:"proxy_port"`
+	Username          string    `json:"username"`
+	SessionId         string    `json:"session_id"`
+	Expiration        time.Time `json:"expiration"`
+	IncludeFile       string    `json:"include_file"`
+	ConnectedAt       time.Time `json:"connected_at"`
+	// Controller and TokenId identify the auth token used to cancel the session
+	Controller string `json:"controller,omitempty"`
+	TokenId    string `json:"token_id,omitempty"`
+}
+
+type registryFile struct {
+	Version  int                `json:"version"`
+	Sessions map[string]Session `json:"sessions"`
+}
+
+// Registry is the persistent record of sessions owned by pgboundary
+type Registry struct {
+	Path string
+}
+
+// Default returns the registry in the state directory
+func Default() *Registry {
+	return &Registry{Path: filepath.Join(Dir, "sessions.json")}
+}
+
+// Load returns all registered sessions keyed by target name
+func (r *Registry) Load() (map[string]Session, error) {
+	content, err := os.ReadFile(r.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]Session), nil
+		}
+		return nil, fmt.Errorf("failed to read session registry: %w", err)
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse session registry %s: %w", r.Path, err)
+	}
+	if file.Version != registryVersion {
+		return nil, fmt.Errorf("unsupported session registry version %d in %s", file.Version, r.Path)
+	}
+	if file.Sessions == nil {
+		file.Sessions = make(map[string]Session)
+	}
+	return file.Sessions, nil
+}
+
+// List returns all registered sessions sorted by target name
+func (r *Registry) List() ([]Session, error) {
+	sessions, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Target < list[j].Target })
+	return list, nil
+}
+
+// Get returns the session for a target, or nil if there is none
+func (r *Registry) Get(target string) (*Session, error) {
+	sessions, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := sessions[target]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// Put registers a session, replacing any previous session for the same target
+func (r *Registry) Put(session Session) error {
+	sessions, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	sessions[session.Target] = session
+	return r.save(sessions)
+}
+
+// Remove unregisters the session for a target and returns it, or nil if there was none
+func (r *Registry) Remove(target string) (*Session, error) {
+	sessions, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := sessions[target]
+	if !ok {
+		return nil, nil
+	}
+	delete(sessions, target)
+	return &session, r.save(sessions)
+}
+
+// Clear unregisters all sessions and returns them
+func (r *Registry) Clear() ([]Session, error) {
+	sessions, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return sessions, r.save(make(map[string]Session))
+}
+
+func (r *Registry) save(sessions map[string]Session) error {
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(registryFile{Version: registryVersion, Sessions: sessions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session registry: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(r.Path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write session registry: %w", err)
+	}
+	return nil
+}
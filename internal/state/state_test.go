@@ -0,0 +1,72 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := &Registry{Path: filepath.Join(t.TempDir(), "state", "sessions.json")}
+
+	sessions, err := registry.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("List() on empty registry = %v", sessions)
+	}
+
+	expiration := time.Now().Add(8 * time.Hour).Truncate(time.Second)
+	for _, target := range []string{"demo-stage", "demo-dev"} {
+		if err := registry.Put(Session{
+			Target:      target,
+			BoundaryPid: 4242,
+			ProxyHost:   "127.0.0.1",
+			ProxyPort:   "36775",
+			SessionId:   "s_" + target,
+			Expiration:  expiration,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(registry.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("registry permissions = %o, want 600", perm)
+	}
+
+	sessions, err = registry.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].Target != "demo-dev" || sessions[1].Target != "demo-stage" {
+		t.Fatalf("List() = %+v, want demo-dev and demo-stage", sessions)
+	}
+	if !sessions[0].Expiration.Equal(expiration) {
+		t.Errorf("Expiration = %v, want %v", sessions[0].Expiration, expiration)
+	}
+
+	removed, err := registry.Remove("demo-dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed == nil || removed.SessionId != "s_demo-dev" {
+		t.Errorf("Remove() = %+v, want session s_demo-dev", removed)
+	}
+	if session, _ := registry.Get("demo-dev"); session != nil {
+		t.Errorf("Get() after Remove() = %+v", session)
+	}
+
+	cleared, err := registry.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleared) != 1 || cleared[0].Target != "demo-stage" {
+		t.Errorf("Clear() = %+v, want demo-stage", cleared)
+	}
+}